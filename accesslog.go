@@ -0,0 +1,320 @@
+package hopter
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	//AccessLogJSON 访问日志以字段形式输出，由日志格式决定最终是json还是文本
+	AccessLogJSON = "json"
+	//AccessLogCommon Common Log Format
+	AccessLogCommon = "common"
+	//AccessLogCombined Combined Log Format
+	AccessLogCombined = "combined"
+	// clfTimeFormat Common Log Format的时间格式
+	clfTimeFormat = "02/Jan/2006:15:04:05 -0700"
+)
+
+// accessLogConfig 访问日志配置参数
+type accessLogConfig struct {
+	// 是否开启访问日志
+	Enable bool `mapstructure:"enable" default:"true"`
+	// 日志格式 json|common|combined
+	Format string `mapstructure:"format" default:"json" validate:"oneof=json common combined"`
+	// json格式输出的字段，为空时输出全部字段
+	Fields []string `mapstructure:"fields"`
+	// 采样比例，0~1，状态码>=500的请求总是记录
+	SampleRate float64 `mapstructure:"sampleRate" default:"1" validate:"gte=0,lte=1"`
+	// 不记录的路径，以*结尾时按前缀匹配
+	Exclude []string `mapstructure:"exclude" default:"/metrics,/health"`
+	// 用户标识在gin.Context中的键，默认与gin.BasicAuth一致
+	UserKey string `mapstructure:"userKey" default:"user"`
+	// 请求和响应内容记录
+	Body accessLogBody `mapstructure:"body"`
+}
+
+// accessLogBody 请求和响应内容记录配置
+type accessLogBody struct {
+	// 是否记录请求和响应内容
+	Enable bool `mapstructure:"enable"`
+	// 最多记录的字节数
+	MaxSize int `mapstructure:"maxSize" default:"4096" validate:"min=0"`
+	// 需要脱敏的字段关键字，json和表单内容中字段名包含关键字时脱敏
+	Redact []string `mapstructure:"redact" default:"key,secret,password,token"`
+}
+
+// accessLog 访问日志
+type accessLog struct {
+	accessLogConfig
+	fields map[string]bool
+}
+
+// LogMiddleware 日志插件，使用默认配置记录访问日志
+func LogMiddleware() gin.HandlerFunc {
+	var value accessLogConfig
+	_ = setDefaults(reflect.ValueOf(&value).Elem())
+	return newAccessLog(value).handler
+}
+
+// accessLogMiddleware 按配置创建访问日志中间件
+func accessLogMiddleware(conf Config) (gin.HandlerFunc, error) {
+	value, err := Bind[accessLogConfig](conf, "accessLog")
+	if err != nil {
+		return nil, err
+	}
+	return newAccessLog(value).handler, nil
+}
+
+func newAccessLog(value accessLogConfig) *accessLog {
+	a := &accessLog{accessLogConfig: value}
+	if len(value.Fields) > 0 {
+		a.fields = make(map[string]bool, len(value.Fields))
+		for _, f := range value.Fields {
+			a.fields[strings.TrimSpace(f)] = true
+		}
+	}
+	return a
+}
+
+// excluded 路径是否不记录
+func (a *accessLog) excluded(c *gin.Context) bool {
+	for _, p := range a.Exclude {
+		if prefix, ok := strings.CutSuffix(p, "*"); ok {
+			if strings.HasPrefix(c.Request.URL.Path, prefix) {
+				return true
+			}
+			continue
+		}
+		if p == c.Request.URL.Path || p == c.FullPath() {
+			return true
+		}
+	}
+	return false
+}
+
+// countReader 统计读取的请求字节数
+type countReader struct {
+	io.ReadCloser
+	n int64
+}
+
+func (r *countReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.n += int64(n)
+	return n, err
+}
+
+// bodyWriter 记录响应内容的前max个字节
+type bodyWriter struct {
+	gin.ResponseWriter
+	body *bytes.Buffer
+	max  int
+}
+
+func (w *bodyWriter) Write(p []byte) (int, error) {
+	w.capture(p)
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *bodyWriter) WriteString(s string) (int, error) {
+	w.capture([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *bodyWriter) capture(p []byte) {
+	if remain := w.max - w.body.Len(); remain > 0 {
+		w.body.Write(p[:min(len(p), remain)])
+	}
+}
+
+func (a *accessLog) handler(c *gin.Context) {
+	if !a.Enable || a.excluded(c) {
+		c.Next()
+		return
+	}
+	start := time.Now()
+	var reqBody []byte
+	if a.Body.Enable && captured(c.ContentType()) {
+		// 只预读max字节，剩余内容仍由处理函数读取
+		reqBody, _ = io.ReadAll(io.LimitReader(c.Request.Body, int64(a.Body.MaxSize)))
+		c.Request.Body = readCloser{io.MultiReader(bytes.NewReader(reqBody), c.Request.Body), c.Request.Body}
+	}
+	counter := &countReader{ReadCloser: c.Request.Body}
+	c.Request.Body = counter
+	var writer *bodyWriter
+	if a.Body.Enable {
+		writer = &bodyWriter{ResponseWriter: c.Writer, body: new(bytes.Buffer), max: a.Body.MaxSize}
+		c.Writer = writer
+	}
+	c.Next()
+	status := c.Writer.Status()
+	if status < http.StatusInternalServerError && a.SampleRate < 1 && rand.Float64() >= a.SampleRate {
+		return
+	}
+	latency := time.Since(start)
+	entry := logrus.NewEntry(logrus.StandardLogger())
+	if logs != nil {
+		entry = logrus.NewEntry(logs.Logger)
+	}
+	entry = entry.WithContext(c.Request.Context())
+	switch a.Format {
+	case AccessLogCommon, AccessLogCombined:
+		entry.Log(accessLevel(status), a.line(c, start))
+		return
+	}
+	fields := logrus.Fields{
+		"method":    c.Request.Method,
+		"path":      c.Request.URL.Path,
+		"route":     c.FullPath(),
+		"query":     c.Request.URL.RawQuery,
+		"protocol":  c.Request.Proto,
+		"status":    status,
+		"latency":   float64(latency.Microseconds()) / 1000,
+		"clientIp":  c.ClientIP(),
+		"userAgent": c.Request.UserAgent(),
+		"referer":   c.Request.Referer(),
+		"bytesIn":   counter.n,
+		"bytesOut":  max(c.Writer.Size(), 0),
+		"requestId": requestID(c),
+		"user":      a.user(c),
+	}
+	if len(c.Errors) > 0 {
+		fields["errors"] = c.Errors.Errors()
+	}
+	if a.Body.Enable {
+		// 处理函数未读取请求体时按Content-Length计算
+		fields["requestBody"] = a.redact(c.ContentType(), reqBody, max(counter.n, c.Request.ContentLength))
+		fields["responseBody"] = a.redact(c.Writer.Header().Get("Content-Type"), writer.body.Bytes(), int64(max(c.Writer.Size(), 0)))
+	}
+	if a.fields != nil {
+		for k := range fields {
+			if !a.fields[k] {
+				delete(fields, k)
+			}
+		}
+	}
+	entry.WithFields(fields).Log(accessLevel(status), "access")
+}
+
+// readCloser 预读后的请求体，关闭时关闭原请求体
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// accessLevel 按状态码得到日志级别
+func accessLevel(status int) logrus.Level {
+	switch {
+	case status >= http.StatusInternalServerError:
+		return logrus.ErrorLevel
+	case status >= http.StatusBadRequest:
+		return logrus.WarnLevel
+	}
+	return logrus.InfoLevel
+}
+
+// line Common/Combined Log Format的日志行
+func (a *accessLog) line(c *gin.Context, start time.Time) string {
+	user := a.user(c)
+	if user == "" {
+		user = "-"
+	}
+	size := "-"
+	if c.Writer.Size() > 0 {
+		size = fmt.Sprint(c.Writer.Size())
+	}
+	res := fmt.Sprintf("%s - %s [%s] \"%s %s %s\" %d %s", c.ClientIP(), user, start.Format(clfTimeFormat),
+		c.Request.Method, c.Request.URL.RequestURI(), c.Request.Proto, c.Writer.Status(), size)
+	if a.Format == AccessLogCombined {
+		res += fmt.Sprintf(" %q %q", c.Request.Referer(), c.Request.UserAgent())
+	}
+	return res
+}
+
+// user 得到用户标识，未设置时使用Basic认证的用户名
+func (a *accessLog) user(c *gin.Context) string {
+	if v, ok := c.Get(a.UserKey); ok {
+		return fmt.Sprint(v)
+	}
+	if user, _, ok := c.Request.BasicAuth(); ok {
+		return user
+	}
+	return ""
+}
+
+// captured 是否记录该类型的内容，只记录可以脱敏的json和表单
+func captured(contentType string) bool {
+	return strings.Contains(contentType, "json") || strings.HasPrefix(contentType, "application/x-www-form-urlencoded")
+}
+
+// redact 脱敏请求或响应内容，json和表单内容中的敏感字段替换为******，
+// 其他类型无法脱敏，只记录大小和类型，size为内容的总字节数
+func (a *accessLog) redact(contentType string, body []byte, size int64) any {
+	if size == 0 {
+		return nil
+	}
+	if !captured(contentType) {
+		return fmt.Sprintf("[%d字节,%s]", size, contentType)
+	}
+	switch {
+	case strings.Contains(contentType, "json"):
+		var v any
+		if err := json.Unmarshal(body, &v); err != nil {
+			// 内容被截断时无法解析，不输出原文以免泄露敏感字段
+			return fmt.Sprintf("[%d字节,无法解析]", len(body))
+		}
+		return a.redactValue(v)
+	default:
+		form, err := url.ParseQuery(string(body))
+		if err != nil {
+			return fmt.Sprintf("[%d字节,无法解析]", len(body))
+		}
+		for k := range form {
+			if a.sensitive(k) {
+				form[k] = []string{redacted}
+			}
+		}
+		return form.Encode()
+	}
+}
+
+func (a *accessLog) redactValue(v any) any {
+	switch value := v.(type) {
+	case map[string]any:
+		for k, item := range value {
+			if a.sensitive(k) {
+				value[k] = redacted
+				continue
+			}
+			value[k] = a.redactValue(item)
+		}
+	case []any:
+		for i, item := range value {
+			value[i] = a.redactValue(item)
+		}
+	}
+	return v
+}
+
+// sensitive 字段名是否包含脱敏关键字
+func (a *accessLog) sensitive(key string) bool {
+	key = strings.ToLower(key)
+	for _, word := range a.Body.Redact {
+		if strings.Contains(key, strings.ToLower(word)) {
+			return true
+		}
+	}
+	return false
+}
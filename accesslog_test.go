@@ -0,0 +1,154 @@
+package hopter
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// newAccessLogRouter 访问日志写入buf的路由，fn修改默认配置
+func newAccessLogRouter(t *testing.T, fn func(*accessLogConfig)) (*gin.Engine, *bytes.Buffer) {
+	t.Helper()
+	var value accessLogConfig
+	if err := setDefaults(reflect.ValueOf(&value).Elem()); err != nil {
+		t.Fatal(err)
+	}
+	if fn != nil {
+		fn(&value)
+	}
+	var buf bytes.Buffer
+	std := logrus.StandardLogger()
+	prevLogs, prevOut, prevFormatter := logs, std.Out, std.Formatter
+	logs = nil
+	std.SetOutput(&buf)
+	std.SetFormatter(&logrus.JSONFormatter{})
+	t.Cleanup(func() {
+		logs = prevLogs
+		std.SetOutput(prevOut)
+		std.SetFormatter(prevFormatter)
+	})
+	r := gin.New()
+	r.Use(newAccessLog(value).handler)
+	return r, &buf
+}
+
+// accessEntries 解析访问日志
+func accessEntries(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var res []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var entry map[string]any
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("log line %q: %v", line, err)
+		}
+		res = append(res, entry)
+	}
+	return res
+}
+
+func TestAccessLogLatency(t *testing.T) {
+	r, buf := newAccessLogRouter(t, nil)
+	r.GET("/slow", func(ctx *gin.Context) {
+		time.Sleep(5 * time.Millisecond)
+		ctx.String(http.StatusOK, "ok")
+	})
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/slow", nil))
+	entries := accessEntries(t, buf)
+	if len(entries) != 1 {
+		t.Fatalf("entries = %v", entries)
+	}
+	if latency, _ := entries[0]["latency"].(float64); latency < 5 {
+		t.Fatalf("latency = %v, want >= 5ms", entries[0]["latency"])
+	}
+	if entries[0]["route"] != "/slow" || entries[0]["status"] != float64(http.StatusOK) {
+		t.Fatalf("entry = %v", entries[0])
+	}
+}
+
+func TestAccessLogExclude(t *testing.T) {
+	r, buf := newAccessLogRouter(t, func(c *accessLogConfig) {
+		c.Exclude = append(c.Exclude, "/static/*")
+	})
+	r.GET("/*path", func(ctx *gin.Context) {
+		ctx.Status(http.StatusOK)
+	})
+	for _, path := range []string{"/health", "/metrics", "/static/app.js", "/api"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+	entries := accessEntries(t, buf)
+	if len(entries) != 1 || entries[0]["path"] != "/api" {
+		t.Fatalf("entries = %v, want only /api", entries)
+	}
+}
+
+func TestAccessLogSampling(t *testing.T) {
+	r, buf := newAccessLogRouter(t, func(c *accessLogConfig) {
+		c.SampleRate = 0
+	})
+	r.GET("/ok", func(ctx *gin.Context) {
+		ctx.Status(http.StatusOK)
+	})
+	r.GET("/fail", func(ctx *gin.Context) {
+		ctx.Status(http.StatusInternalServerError)
+	})
+	for i := 0; i < 10; i++ {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/ok", nil))
+	}
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/fail", nil))
+	entries := accessEntries(t, buf)
+	if len(entries) != 1 || entries[0]["path"] != "/fail" || entries[0]["level"] != "error" {
+		t.Fatalf("entries = %v, want only the 5xx request", entries)
+	}
+}
+
+func TestAccessLogBodyRedact(t *testing.T) {
+	r, buf := newAccessLogRouter(t, func(c *accessLogConfig) {
+		c.Body.Enable = true
+	})
+	r.POST("/json", func(ctx *gin.Context) {
+		var body map[string]any
+		_ = ctx.ShouldBindJSON(&body)
+		ctx.JSON(http.StatusOK, gin.H{"token": "t", "name": body["name"]})
+	})
+	r.POST("/text", func(ctx *gin.Context) {
+		ctx.String(http.StatusOK, "password=secret")
+	})
+	req := httptest.NewRequest(http.MethodPost, "/json", strings.NewReader(`{"name":"a","password":"p"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	// 记录请求内容不影响处理函数读取
+	if !strings.Contains(w.Body.String(), `"name":"a"`) {
+		t.Fatalf("response = %s", w.Body.String())
+	}
+	req = httptest.NewRequest(http.MethodPost, "/text", strings.NewReader("password=secret"))
+	req.Header.Set("Content-Type", "text/plain")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+	entries := accessEntries(t, buf)
+	if len(entries) != 2 {
+		t.Fatalf("entries = %v", entries)
+	}
+	reqBody, _ := entries[0]["requestBody"].(map[string]any)
+	respBody, _ := entries[0]["responseBody"].(map[string]any)
+	if reqBody["password"] != redacted || reqBody["name"] != "a" || respBody["token"] != redacted {
+		t.Fatalf("json bodies = %v, %v", entries[0]["requestBody"], entries[0]["responseBody"])
+	}
+	// 其他类型无法脱敏，不记录原文
+	if entries[1]["requestBody"] != "[15字节,text/plain]" || entries[1]["responseBody"] != "[15字节,text/plain; charset=utf-8]" {
+		t.Fatalf("text bodies = %v, %v", entries[1]["requestBody"], entries[1]["responseBody"])
+	}
+	if strings.Contains(buf.String(), "secret") {
+		t.Fatalf("log leaks body: %s", buf.String())
+	}
+}
@@ -0,0 +1,77 @@
+package hopter
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/pprof"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// adminConfig 管理端口配置，端口为空时不开启管理端口
+type adminConfig struct {
+	// 默认只监听本机，pprof和配置等接口不对外暴露
+	IP   string `mapstructure:"ip" default:"127.0.0.1" validate:"omitempty,ip|hostname"`
+	Port string `mapstructure:"port" validate:"omitempty,numeric"`
+}
+
+// initAdmin 初始化管理端口，挂载metrics、pprof、健康检查、路由列表和配置
+func (e *Engine) initAdmin(conf Config) error {
+	value, err := Bind[adminConfig](conf, "admin")
+	if err != nil {
+		return err
+	}
+	if value.Port == "" {
+		return nil
+	}
+	e.admin = gin.New()
+	e.admin.Use(gin.Recovery())
+	e.adminServer = &http.Server{
+		Addr:              fmt.Sprintf("%s:%s", value.IP, value.Port),
+		Handler:           e.admin,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	e.admin.GET(healthPath, e.health)
+	e.admin.GET("/routes", e.routeList)
+	e.admin.GET("/config", e.dumpConfig)
+	e.admin.GET("/beans", e.beanGraph)
+	e.admin.GET("/debug/pprof/*name", pprofHandler)
+	e.admin.GET("/openapi.json", e.openAPIHandler)
+	return nil
+}
+
+// routeList 路由列表
+func (e *Engine) routeList(ctx *gin.Context) {
+	res := make([]gin.H, 0)
+	for _, v := range e.engine.Routes() {
+		res = append(res, gin.H{"method": v.Method, "path": v.Path, "handler": v.Handler})
+	}
+	ctx.JSON(http.StatusOK, res)
+}
+
+// dumpConfig 输出当前配置，敏感字段已脱敏
+func (e *Engine) dumpConfig(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, e.Endpoint.Config().Redacted())
+}
+
+// beanGraph 输出bean依赖图
+func (e *Engine) beanGraph(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, e.beanFactory.Graph())
+}
+
+// pprofHandler pprof处理函数
+func pprofHandler(ctx *gin.Context) {
+	switch ctx.Param("name") {
+	case "/cmdline":
+		pprof.Cmdline(ctx.Writer, ctx.Request)
+	case "/profile":
+		pprof.Profile(ctx.Writer, ctx.Request)
+	case "/symbol":
+		pprof.Symbol(ctx.Writer, ctx.Request)
+	case "/trace":
+		pprof.Trace(ctx.Writer, ctx.Request)
+	default:
+		pprof.Index(ctx.Writer, ctx.Request)
+	}
+}
@@ -0,0 +1,50 @@
+package hopter
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+// statusOf 请求url返回的状态码
+func statusOf(t *testing.T, url string) int {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestMetricsOnAdminPort(t *testing.T) {
+	adminPort := freePort(t)
+	e, addr := newTestServer(t, map[string]any{"admin.port": adminPort})
+	done := runTestServer(t, context.Background(), e, addr)
+	defer func() {
+		_ = e.Shutdown(context.Background())
+		_ = waitRun(t, done)
+	}()
+	waitReady(t, e, done)
+	admin := "http://127.0.0.1:" + adminPort
+	if code := statusOf(t, admin+"/metrics"); code != http.StatusOK {
+		t.Fatalf("admin /metrics = %d, want 200", code)
+	}
+	for _, path := range []string{"/metrics", "/routes", "/config"} {
+		if code := statusOf(t, addr+path); code != http.StatusNotFound {
+			t.Fatalf("public %s = %d, want 404", path, code)
+		}
+	}
+}
+
+func TestMetricsWithoutAdmin(t *testing.T) {
+	e, addr := newTestServer(t, nil)
+	done := runTestServer(t, context.Background(), e, addr)
+	defer func() {
+		_ = e.Shutdown(context.Background())
+		_ = waitRun(t, done)
+	}()
+	if code := statusOf(t, addr+"/metrics"); code != http.StatusOK {
+		t.Fatalf("public /metrics = %d, want 200", code)
+	}
+}
@@ -1,70 +1,656 @@
 package hopter
 
 import (
+	"errors"
+	"fmt"
 	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unsafe"
 )
 
-// BeanFactory Bean工厂
+// ErrBeanNotFound 没有找到可注入的bean
+var ErrBeanNotFound = errors.New("没有找到可注入的bean")
+
+// BeanDefinition bean定义，用于给bean指定名称、延迟创建等选项
+type BeanDefinition struct {
+	name  string
+	value any
+	// typ bean声明的类型，构造函数为其第一个返回值类型
+	typ         reflect.Type
+	constructor reflect.Value
+	lazy        bool
+	prototype   bool
+	timeout     time.Duration
+	created     bool
+	err         error
+}
+
+// Named 为bean指定名称，字段可通过`inject:"名称"`按名称注入
+func Named(name string, bean any) *BeanDefinition {
+	d := define(bean)
+	d.name = name
+	return d
+}
+
+// Lazy 构造函数在第一次被依赖时才调用，默认在启动时创建
+func Lazy(bean any) *BeanDefinition {
+	d := define(bean)
+	d.lazy = true
+	return d
+}
+
+// Prototype 每次注入都调用构造函数创建新实例，默认所有注入共享同一个实例
+// 只能用于构造函数
+func Prototype(bean any) *BeanDefinition {
+	d := define(bean)
+	d.prototype = true
+	return d
+}
+
+// define 把bean包装为定义，已是定义的直接返回
+func define(bean any) *BeanDefinition {
+	if d, ok := bean.(*BeanDefinition); ok {
+		return d
+	}
+	d := &BeanDefinition{value: bean, typ: reflect.TypeOf(bean), created: true}
+	if fn := reflect.ValueOf(bean); fn.Kind() == reflect.Func {
+		d.value, d.created, d.constructor = nil, false, fn
+		d.typ = constructorType(fn.Type())
+	}
+	return d
+}
+
+// constructorType 构造函数创建的bean类型，签名须为func(依赖...) T或func(依赖...) (T, error)
+func constructorType(t reflect.Type) reflect.Type {
+	switch {
+	case t.IsVariadic():
+		return nil
+	case t.NumOut() == 1:
+		return t.Out(0)
+	case t.NumOut() == 2 && t.Out(1) == errorType:
+		return t.Out(0)
+	}
+	return nil
+}
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// Name bean名称，未指定时为类型名
+func (d *BeanDefinition) Name() string {
+	if d.name != "" {
+		return d.name
+	}
+	if d.typ == nil {
+		return d.constructor.Type().String()
+	}
+	return d.typ.String()
+}
+
+// BeanFactory Bean工厂，方法可以并发调用
 type BeanFactory struct {
-	beans []any
+	// mu 保护beans、creating、errs和graph，启动后Inject仍可能在请求中并发调用
+	mu    sync.Mutex
+	beans []*BeanDefinition
+	// creating 正在创建的bean，用于检测循环依赖
+	creating []*BeanDefinition
+	errs     []error
+	// graph 依赖图，节点名称 -> 字段或参数 -> bean名称
+	graph map[string]map[string][]string
 }
 
 // NewBeanFactory 创建Bean工厂
 func NewBeanFactory() *BeanFactory {
-	bf := &BeanFactory{beans: make([]any, 0)}
-	bf.beans = append(bf.beans, bf)
+	bf := &BeanFactory{beans: make([]*BeanDefinition, 0)}
+	bf.beans = append(bf.beans, define(bf))
 	return bf
 }
 
-// set 往内存中塞入bean
+// set 往内存中塞入bean，构造函数签名错误在启动时报告
 func (b *BeanFactory) set(beans ...any) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
 	for _, p := range beans {
-		obj := reflect.TypeOf(p)
-		if obj.Kind() == reflect.Func {
-			conf := b.get(new(Endpoint)).(*Endpoint)
-			b.beans = append(b.beans, p.(func(conf Endpoint) any)(*conf))
+		if p == nil {
+			continue
+		}
+		d := define(p)
+		if d.constructor.IsValid() && d.typ == nil {
+			b.errs = append(b.errs, fmt.Errorf("bean %s的构造函数签名不正确,应为func(依赖...) T或func(依赖...) (T, error)", d.Name()))
+			continue
+		}
+		if d.prototype && !d.constructor.IsValid() {
+			b.errs = append(b.errs, fmt.Errorf("bean %s不是构造函数,不能声明为Prototype", d.Name()))
 			continue
 		}
-		b.beans = append(b.beans, p)
+		b.beans = append(b.beans, d)
+		// 返回any的构造函数只有调用后才知道类型，注册时立即创建，失败时在启动时报告
+		if !d.created && d.typ.Kind() == reflect.Interface && d.typ.NumMethod() == 0 {
+			_, _ = b.instance(d)
+		}
 	}
 }
 
 // get 外部使用
 func (b *BeanFactory) get(bean any) any {
+	b.mu.Lock()
+	defer b.mu.Unlock()
 	return b.find(reflect.TypeOf(bean))
 }
 
-// find 得到内存中预先设置好的bean对象
+// find 得到内存中预先设置好的bean对象，没有或不唯一时返回nil
 func (b *BeanFactory) find(t reflect.Type) any {
-	for _, p := range b.beans {
-		if t == reflect.TypeOf(p) {
-			return p
+	d, err := b.lookup(t, "")
+	if err != nil {
+		return nil
+	}
+	v, err := b.instance(d)
+	if err != nil {
+		return nil
+	}
+	return v.Interface()
+}
+
+// lookup 按名称或类型查找bean
+// 按类型查找时匹配所有可赋值给t的bean，多个候选中类型完全一致的唯一bean优先，否则视为歧义
+func (b *BeanFactory) lookup(t reflect.Type, name string) (*BeanDefinition, error) {
+	if name != "" {
+		for _, d := range b.beans {
+			if d.name != name {
+				continue
+			}
+			if !d.typ.AssignableTo(t) {
+				return nil, fmt.Errorf("bean %s的类型%s不能赋值给%s", name, d.typ, t)
+			}
+			return d, nil
 		}
+		return nil, fmt.Errorf("%w:名称%s", ErrBeanNotFound, name)
 	}
-	return nil
+	var candidates, exact []*BeanDefinition
+	for _, d := range b.beans {
+		if d.typ == t {
+			exact = append(exact, d)
+		}
+		if d.typ.AssignableTo(t) {
+			candidates = append(candidates, d)
+		}
+	}
+	switch {
+	case len(candidates) == 1:
+		return candidates[0], nil
+	case len(candidates) == 0:
+		return nil, fmt.Errorf("%w:类型%s", ErrBeanNotFound, t)
+	case len(exact) == 1:
+		return exact[0], nil
+	}
+	return nil, b.ambiguous(t, candidates)
+}
+
+// lookupExact 按类型完全一致查找bean，用于未打inject标签的字段
+func (b *BeanFactory) lookupExact(t reflect.Type) (*BeanDefinition, error) {
+	var exact []*BeanDefinition
+	for _, d := range b.beans {
+		if d.typ == t {
+			exact = append(exact, d)
+		}
+	}
+	switch len(exact) {
+	case 0:
+		return nil, fmt.Errorf("%w:类型%s", ErrBeanNotFound, t)
+	case 1:
+		return exact[0], nil
+	}
+	return nil, b.ambiguous(t, exact)
+}
+
+// ambiguous 多个bean匹配时的错误，未命名的bean以注册顺序区分，如*pkg.Repo#1
+func (b *BeanFactory) ambiguous(t reflect.Type, candidates []*BeanDefinition) error {
+	names := make([]string, len(candidates))
+	for i, d := range candidates {
+		names[i] = d.Name()
+		if d.name != "" {
+			continue
+		}
+		for j, v := range b.beans {
+			if v == d {
+				names[i] = fmt.Sprintf("%s#%d", d.Name(), j)
+				break
+			}
+		}
+	}
+	return fmt.Errorf("类型%s匹配到多个bean:%s,请使用inject标签指定名称", t, strings.Join(names, ","))
+}
+
+// instance 得到bean实例，构造函数的依赖先于自身创建，Prototype每次都新建
+func (b *BeanFactory) instance(d *BeanDefinition) (reflect.Value, error) {
+	if d.created {
+		return reflect.ValueOf(d.value), nil
+	}
+	if d.err != nil {
+		return reflect.Value{}, d.err
+	}
+	for i, c := range b.creating {
+		if c != d {
+			continue
+		}
+		chain := make([]string, 0, len(b.creating)-i+1)
+		for _, c := range b.creating[i:] {
+			chain = append(chain, c.Name())
+		}
+		err := &cycleError{fmt.Sprintf("bean循环依赖:%s -> %s", strings.Join(chain, " -> "), d.Name())}
+		// 环上的bean都无法创建，只报告一次
+		for _, c := range b.creating[i:] {
+			c.err = err
+		}
+		return reflect.Value{}, err
+	}
+	b.creating = append(b.creating, d)
+	defer func() {
+		b.creating = b.creating[:len(b.creating)-1]
+	}()
+	fn := d.constructor.Type()
+	args := make([]reflect.Value, fn.NumIn())
+	for i := range args {
+		arg, dep, err := b.dependency(fn.In(i))
+		if dep != nil {
+			b.link(d.Name(), fmt.Sprintf("参数%d", i+1), dep.Name())
+		}
+		var cycle *cycleError
+		if errors.As(err, &cycle) {
+			return reflect.Value{}, err
+		}
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("bean %s的第%d个参数:%w", d.Name(), i+1, err)
+		}
+		args[i] = arg
+	}
+	out := d.constructor.Call(args)
+	if len(out) == 2 && !out[1].IsNil() {
+		err := fmt.Errorf("bean %s创建失败:%w", d.Name(), out[1].Interface().(error))
+		if !d.prototype {
+			d.err = err
+		}
+		return reflect.Value{}, err
+	}
+	if d.prototype {
+		// 新建的实例立即注入，单例在refresh时注入
+		if err := b.inject(out[0].Interface()); err != nil {
+			return reflect.Value{}, err
+		}
+		return out[0], nil
+	}
+	d.value, d.created = out[0].Interface(), true
+	if d.value != nil && d.typ.Kind() == reflect.Interface && d.typ.NumMethod() == 0 {
+		d.typ = reflect.TypeOf(d.value)
+	}
+	return reflect.ValueOf(d.value), nil
+}
+
+// dependency 解析构造函数参数，结构体参数可由其指针bean提供
+func (b *BeanFactory) dependency(t reflect.Type) (reflect.Value, *BeanDefinition, error) {
+	d, err := b.lookup(t, "")
+	if errors.Is(err, ErrBeanNotFound) && t.Kind() == reflect.Struct {
+		if pd, perr := b.lookup(reflect.PointerTo(t), ""); perr == nil {
+			v, err := b.instance(pd)
+			if err != nil {
+				return reflect.Value{}, pd, err
+			}
+			return v.Elem(), pd, nil
+		}
+	}
+	if err != nil {
+		return reflect.Value{}, nil, err
+	}
+	v, err := b.instance(d)
+	if err != nil {
+		return reflect.Value{}, d, err
+	}
+	if !v.IsValid() {
+		// 构造函数返回nil接口
+		return reflect.Zero(t), d, nil
+	}
+	return v, d, nil
+}
+
+// cycleError 循环依赖错误，不再逐层包装
+type cycleError struct {
+	msg string
+}
+
+func (e *cycleError) Error() string {
+	return e.msg
+}
+
+// refresh 创建所有非延迟的单例bean，依赖按拓扑顺序先创建，汇总所有错误
+func (b *BeanFactory) refresh() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	errs := b.errs
+	seen := make(map[error]bool)
+	for _, d := range b.beans {
+		if d.lazy || d.prototype {
+			continue
+		}
+		if _, err := b.instance(d); err != nil && !seen[err] {
+			seen[err] = true
+			errs = append(errs, err)
+		}
+	}
+	// 所有单例创建完成后再注入字段，字段之间的循环引用不影响创建
+	for _, d := range b.beans {
+		if d.created {
+			errs = append(errs, b.inject(d.value))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// injectTag 解析inject标签，格式为`inject:"名称,optional"`
+type injectTag struct {
+	tagged   bool
+	name     string
+	optional bool
+}
+
+func parseInjectTag(field reflect.StructField) injectTag {
+	tag, ok := field.Tag.Lookup("inject")
+	if !ok {
+		// 未打标签的字段按旧规则尽量注入，找不到时忽略
+		return injectTag{optional: true}
+	}
+	res := injectTag{tagged: true}
+	parts := strings.Split(tag, ",")
+	res.name = strings.TrimSpace(parts[0])
+	for _, opt := range parts[1:] {
+		if strings.TrimSpace(opt) == "optional" {
+			res.optional = true
+		}
+	}
+	return res
 }
 
 // Inject 把bean注入到控制器中
-func (b *BeanFactory) Inject(object any) {
+// 未打标签的nil指针字段按类型完全一致注入，找不到时忽略；
+// 打了inject标签的指针或接口字段注入可赋值的bean，默认必须注入，标签带optional时找不到忽略，未导出字段只注入打了标签的；
+// 打了标签的切片和map字段注入所有匹配的bean，map以bean名称为键；
+// 嵌入的结构体和已经是bean的字段会递归注入；
+// 可以并发调用，但不能在bean的构造函数中调用
+func (b *BeanFactory) Inject(object any) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.inject(object)
+}
+
+// inject Inject的实现，调用时需持有锁
+func (b *BeanFactory) inject(object any) error {
 	vObject := reflect.ValueOf(object)
 	if vObject.Kind() == reflect.Ptr {
 		//由于不是控制器 ，所以传过来的值 不一定是指针。因此要做判断
 		vObject = vObject.Elem()
 	}
-	for i := 0; i < vObject.NumField(); i++ {
-		f := vObject.Field(i)
-		if f.Kind() != reflect.Ptr || !f.IsNil() {
+	if vObject.Kind() != reflect.Struct {
+		return nil
+	}
+	owner := reflect.TypeOf(object).String()
+	if d := b.definitionOf(reflect.ValueOf(object)); d != nil {
+		owner = d.Name()
+	}
+	return errors.Join(b.injectStruct(vObject, owner, "", make(map[visit]bool))...)
+}
+
+// visit 已注入的结构体，嵌入的第一个字段和外层地址相同，需要同时比较类型
+type visit struct {
+	addr uintptr
+	typ  reflect.Type
+}
+
+// injectStruct 注入结构体的字段，owner为依赖图中的节点名称，prefix为嵌入字段的路径
+func (b *BeanFactory) injectStruct(v reflect.Value, owner, prefix string, visited map[visit]bool) []error {
+	if v.CanAddr() {
+		key := visit{v.UnsafeAddr(), v.Type()}
+		if visited[key] {
+			return nil
+		}
+		visited[key] = true
+	}
+	var errs []error
+	for i := 0; i < v.NumField(); i++ {
+		f := v.Field(i)
+		field := v.Type().Field(i)
+		tag := parseInjectTag(field)
+		path := prefix + field.Name
+		if !field.IsExported() {
+			if !tag.tagged && !field.Anonymous || !f.CanAddr() {
+				continue
+			}
+			// 未导出字段通过标签显式开启注入
+			f = reflect.NewAt(f.Type(), unsafe.Pointer(f.UnsafeAddr())).Elem()
+		}
+		if field.Anonymous && !tag.tagged && f.Kind() == reflect.Struct {
+			errs = append(errs, b.injectStruct(f, owner, path+".", visited)...)
+			continue
+		}
+		if !field.IsExported() && !tag.tagged {
+			continue
+		}
+		switch {
+		case tag.tagged && isCollection(f):
+			if err := b.injectCollection(f, tag, owner, path); err != nil {
+				errs = append(errs, fmt.Errorf("%s.%s注入失败:%w", owner, path, err))
+			}
+			continue
+		case injectable(f, tag):
+			lookup := b.lookup
+			if !tag.tagged {
+				// 未打标签的字段只按类型完全一致注入，避免接口或可赋值类型匹配到无关的bean
+				lookup = func(t reflect.Type, _ string) (*BeanDefinition, error) {
+					return b.lookupExact(t)
+				}
+			}
+			d, err := lookup(f.Type(), tag.name)
+			if err != nil {
+				if tag.optional && errors.Is(err, ErrBeanNotFound) {
+					continue
+				}
+				errs = append(errs, fmt.Errorf("%s.%s注入失败:%w", owner, path, err))
+				continue
+			}
+			val, err := b.instance(d)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s.%s注入失败:%w", owner, path, err))
+				continue
+			}
+			if !val.IsValid() {
+				continue
+			}
+			// 单例bean直接共享同一个实例，不复制
+			f.Set(val)
+			b.link(owner, path, d.Name())
+		}
+		// 已经是bean的字段递归注入
+		if d := b.definitionOf(f); d != nil {
+			b.link(owner, path, d.Name())
+			if e := reflect.ValueOf(d.value).Elem(); e.Kind() == reflect.Struct {
+				errs = append(errs, b.injectStruct(e, d.Name(), "", visited)...)
+			}
+		}
+	}
+	return errs
+}
+
+// isCollection 字段是否为bean集合，支持[]T和map[string]T
+func isCollection(f reflect.Value) bool {
+	switch f.Kind() {
+	case reflect.Slice:
+		return true
+	case reflect.Map:
+		return f.Type().Key().Kind() == reflect.String
+	}
+	return false
+}
+
+// injectCollection 注入所有可赋值给元素类型的bean，字段已有值时不覆盖
+func (b *BeanFactory) injectCollection(f reflect.Value, tag injectTag, owner, path string) error {
+	if f.Len() > 0 {
+		return nil
+	}
+	elem := f.Type().Elem()
+	var matched []*BeanDefinition
+	for _, d := range b.beans {
+		if d.typ.AssignableTo(elem) {
+			matched = append(matched, d)
+		}
+	}
+	if len(matched) == 0 {
+		if tag.optional {
+			return nil
+		}
+		return fmt.Errorf("%w:类型%s", ErrBeanNotFound, elem)
+	}
+	var res reflect.Value
+	if f.Kind() == reflect.Map {
+		res = reflect.MakeMapWithSize(f.Type(), len(matched))
+	} else {
+		res = reflect.MakeSlice(f.Type(), 0, len(matched))
+	}
+	names := make([]string, 0, len(matched))
+	for _, d := range matched {
+		v, err := b.instance(d)
+		if err != nil {
+			return err
+		}
+		if !v.IsValid() {
+			continue
+		}
+		if f.Kind() == reflect.Map {
+			res.SetMapIndex(reflect.ValueOf(d.Name()), v)
+		} else {
+			res = reflect.Append(res, v)
+		}
+		names = append(names, d.Name())
+	}
+	f.Set(res)
+	b.link(owner, path, names...)
+	return nil
+}
+
+// injectable 字段是否需要注入，只注入可设置且为nil的指针或接口字段
+func injectable(f reflect.Value, tag injectTag) bool {
+	if !f.CanSet() {
+		return false
+	}
+	switch f.Kind() {
+	case reflect.Ptr:
+		return f.IsNil()
+	case reflect.Interface:
+		// 接口字段只注入打了标签的，否则会匹配到实现了该接口的无关bean
+		return f.IsNil() && tag.tagged
+	}
+	return false
+}
+
+// contains object是否为已创建的单例bean
+func (b *BeanFactory) contains(object any) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.definitionOf(reflect.ValueOf(object)) != nil
+}
+
+// definitionOf 得到指针v对应的单例bean，不是bean时返回nil
+func (b *BeanFactory) definitionOf(v reflect.Value) *BeanDefinition {
+	if v.Kind() == reflect.Interface {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return nil
+	}
+	for _, d := range b.beans {
+		if !d.created {
 			continue
 		}
-		if p := b.find(f.Type()); p != nil && f.CanInterface() {
-			f.Set(reflect.New(f.Type().Elem()))
-			f.Elem().Set(reflect.ValueOf(p).Elem())
+		dv := reflect.ValueOf(d.value)
+		if dv.Kind() == reflect.Ptr && dv.Type() == v.Type() && dv.Pointer() == v.Pointer() {
+			return d
+		}
+	}
+	return nil
+}
+
+// BeanNode bean依赖图中的节点，Dependencies为字段或构造函数参数到bean名称的映射
+type BeanNode struct {
+	Name         string              `json:"name"`
+	Type         string              `json:"type,omitempty"`
+	Scope        string              `json:"scope,omitempty"`
+	Lazy         bool                `json:"lazy,omitempty"`
+	Created      bool                `json:"created"`
+	Dependencies map[string][]string `json:"dependencies,omitempty"`
+}
+
+// link 记录依赖图中的一条边
+func (b *BeanFactory) link(owner, path string, names ...string) {
+	if b.graph == nil {
+		b.graph = make(map[string]map[string][]string)
+	}
+	if b.graph[owner] == nil {
+		b.graph[owner] = make(map[string][]string)
+	}
+	b.graph[owner][path] = names
+}
+
+// Graph 得到bean依赖图，包含注入过的非bean对象(服务、中间件)
+func (b *BeanFactory) Graph() []BeanNode {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	nodes := make([]BeanNode, 0, len(b.beans))
+	seen := make(map[string]bool)
+	for _, d := range b.beans {
+		node := BeanNode{Name: d.Name(), Scope: "singleton", Lazy: d.lazy, Created: d.created, Dependencies: b.graph[d.Name()]}
+		if d.typ != nil {
+			node.Type = d.typ.String()
+		}
+		if d.prototype {
+			node.Scope = "prototype"
+		}
+		seen[node.Name] = true
+		nodes = append(nodes, node)
+	}
+	owners := make([]string, 0)
+	for owner := range b.graph {
+		if !seen[owner] {
+			owners = append(owners, owner)
 		}
 	}
+	sort.Strings(owners)
+	for _, owner := range owners {
+		nodes = append(nodes, BeanNode{Name: owner, Dependencies: b.graph[owner]})
+	}
+	return nodes
 }
 
-// Beans Bean注册
+// Beans Bean注册，可以是实例或构造函数，使用Named指定名称、Lazy延迟创建、Prototype每次注入新建
+// 构造函数的参数从其他bean解析，签名为func(依赖...) T或func(依赖...) (T, error)
 func (e *Engine) Beans(beans ...any) *Engine {
 	e.beanFactory.set(beans...)
 	return e
 }
+
+// inject 创建非延迟的bean并注入启动前登记的对象，汇总所有错误
+func (e *Engine) inject() error {
+	errs := []error{e.beanFactory.refresh()}
+	for _, v := range e.injects {
+		// 挂载的服务同时注册为bean，refresh中已经注入过
+		if e.beanFactory.contains(v) {
+			continue
+		}
+		errs = append(errs, e.beanFactory.Inject(v))
+	}
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("web服务启动失败:%w", err)
+	}
+	for _, node := range e.beanFactory.Graph() {
+		Debug("bean %s %s 依赖:%v", node.Name, node.Scope, node.Dependencies)
+	}
+	return nil
+}
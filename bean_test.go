@@ -0,0 +1,202 @@
+package hopter
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type (
+	beanRepo interface{ Name() string }
+	pgRepo   struct{ name string }
+	memRepo  struct{}
+	// beanService 按类型、名称和集合注入的对象
+	beanService struct {
+		Repo     beanRepo            `inject:"primary"`
+		Pg       *pgRepo             `inject:""`
+		All      []beanRepo          `inject:""`
+		ByName   map[string]beanRepo `inject:""`
+		Optional *memRepo            `inject:",optional"`
+	}
+	cycleA  struct{}
+	cycleB  struct{}
+	counter struct{ n int }
+)
+
+func (r *pgRepo) Name() string  { return r.name }
+func (*memRepo) Name() string   { return "mem" }
+func newCycleA(*cycleB) *cycleA { return &cycleA{} }
+func newCycleB(*cycleA) *cycleB { return &cycleB{} }
+
+func TestLookupAmbiguity(t *testing.T) {
+	b := NewBeanFactory()
+	b.set(Named("primary", &pgRepo{"primary"}), Named("replica", &pgRepo{"replica"}))
+	if _, err := b.lookup(reflect.TypeFor[beanRepo](), ""); err == nil || !strings.Contains(err.Error(), "多个bean") {
+		t.Fatalf("lookup interface = %v, want ambiguity error", err)
+	}
+	d, err := b.lookup(reflect.TypeFor[beanRepo](), "replica")
+	if err != nil || d.value.(*pgRepo).name != "replica" {
+		t.Fatalf("lookup by name = %v, %v", d, err)
+	}
+	if _, err := b.lookup(reflect.TypeFor[*memRepo](), ""); !errors.Is(err, ErrBeanNotFound) {
+		t.Fatalf("lookup missing = %v, want ErrBeanNotFound", err)
+	}
+	if _, err := b.lookup(reflect.TypeFor[*memRepo](), "primary"); err == nil {
+		t.Fatal("lookup by name with wrong type: expected error")
+	}
+}
+
+func TestLookupExactMatch(t *testing.T) {
+	b := NewBeanFactory()
+	// 构造函数声明的类型与字段类型完全一致时优先
+	b.set(&pgRepo{"pg"}, func() beanRepo { return &memRepo{} })
+	d, err := b.lookup(reflect.TypeFor[beanRepo](), "")
+	if err != nil || d.typ != reflect.TypeFor[beanRepo]() {
+		t.Fatalf("lookup exact = %v, %v", d, err)
+	}
+}
+
+func TestInjectCollections(t *testing.T) {
+	b := NewBeanFactory()
+	primary := &pgRepo{"primary"}
+	b.set(Named("primary", primary), Named("mem", &memRepo{}))
+	var s beanService
+	if err := b.Inject(&s); err != nil {
+		t.Fatalf("Inject: %v", err)
+	}
+	if s.Repo != primary || s.Pg != primary {
+		t.Fatal("singleton bean was not shared")
+	}
+	if len(s.All) != 2 || len(s.ByName) != 2 || s.ByName["mem"].Name() != "mem" {
+		t.Fatalf("collections = %v, %v", s.All, s.ByName)
+	}
+	if s.Optional == nil {
+		t.Fatal("optional bean was not injected")
+	}
+}
+
+func TestInjectMissing(t *testing.T) {
+	b := NewBeanFactory()
+	var s beanService
+	err := b.Inject(&s)
+	if !errors.Is(err, ErrBeanNotFound) {
+		t.Fatalf("Inject = %v, want ErrBeanNotFound", err)
+	}
+	if s.Optional != nil {
+		t.Fatal("optional field should stay nil")
+	}
+}
+
+func TestConstructorCycle(t *testing.T) {
+	b := NewBeanFactory()
+	b.set(newCycleA, newCycleB)
+	err := b.refresh()
+	if err == nil || !strings.Contains(err.Error(), "循环依赖") {
+		t.Fatalf("refresh = %v, want cycle error", err)
+	}
+	// 环上的bean只报告一次
+	if n := strings.Count(err.Error(), "循环依赖"); n != 1 {
+		t.Fatalf("cycle reported %d times: %v", n, err)
+	}
+}
+
+func TestPrototype(t *testing.T) {
+	b := NewBeanFactory()
+	created := 0
+	b.set(Prototype(func() *counter {
+		created++
+		return &counter{created}
+	}))
+	if err := b.refresh(); err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+	if created != 0 {
+		t.Fatal("prototype bean was created eagerly")
+	}
+	var s1, s2 struct{ C *counter }
+	_ = b.Inject(&s1)
+	_ = b.Inject(&s2)
+	if s1.C == nil || s2.C == nil || s1.C == s2.C {
+		t.Fatalf("prototype instances = %p, %p", s1.C, s2.C)
+	}
+}
+
+func TestInjectConcurrent(t *testing.T) {
+	b := NewBeanFactory()
+	b.set(Named("primary", &pgRepo{"primary"}), Lazy(func() *memRepo { return &memRepo{} }))
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var s beanService
+			if err := b.Inject(&s); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if len(b.Graph()) == 0 {
+		t.Fatal("empty graph")
+	}
+}
+
+// closerBean 实现了io.Closer的bean
+type closerBean struct{}
+
+func (*closerBean) Close() error { return nil }
+
+func TestInjectUntaggedExactType(t *testing.T) {
+	b := NewBeanFactory()
+	pg := &pgRepo{"pg"}
+	b.set(pg, &closerBean{})
+	var s struct {
+		Closer io.Closer
+		Repo   beanRepo
+		Pg     *pgRepo
+		Mem    *memRepo
+	}
+	if err := b.Inject(&s); err != nil {
+		t.Fatalf("Inject: %v", err)
+	}
+	// 未打标签的接口字段不按可赋值类型注入
+	if s.Closer != nil || s.Repo != nil {
+		t.Fatalf("untagged interface fields = %v, %v, want nil", s.Closer, s.Repo)
+	}
+	if s.Pg != pg || s.Mem != nil {
+		t.Fatalf("untagged pointer fields = %v, %v", s.Pg, s.Mem)
+	}
+}
+
+func TestAmbiguityNames(t *testing.T) {
+	b := NewBeanFactory()
+	b.set(&pgRepo{"a"}, &pgRepo{"b"})
+	_, err := b.lookup(reflect.TypeFor[*pgRepo](), "")
+	if err == nil || !strings.Contains(err.Error(), "*hopter.pgRepo#1,*hopter.pgRepo#2") {
+		t.Fatalf("lookup = %v, want distinguishable candidates", err)
+	}
+}
+
+// injectedSvc 缺少依赖的服务
+type injectedSvc struct {
+	Repo beanRepo `inject:""`
+}
+
+func (*injectedSvc) Init()           {}
+func (*injectedSvc) Handles(*Engine) {}
+
+func TestEngineInjectOnce(t *testing.T) {
+	e := newTestEngine()
+	e.Mount("/svc", &injectedSvc{})
+	err := e.inject()
+	if err == nil {
+		t.Fatal("inject: expected missing bean error")
+	}
+	// 服务既是bean又在injects中，错误只报告一次
+	if n := strings.Count(err.Error(), "injectedSvc.Repo"); n != 1 {
+		t.Fatalf("error reported %d times: %v", n, err)
+	}
+}
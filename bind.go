@@ -0,0 +1,232 @@
+package hopter
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/go-viper/mapstructure/v2"
+)
+
+var (
+	validateOnce   sync.Once
+	configValidate *validator.Validate
+)
+
+// getValidate 配置校验器，错误信息中的字段名使用配置中的key
+func getValidate() *validator.Validate {
+	validateOnce.Do(func() {
+		configValidate = validator.New(validator.WithRequiredStructEnabled())
+		configValidate.RegisterTagNameFunc(func(field reflect.StructField) string {
+			name, _, _ := strings.Cut(field.Tag.Get("mapstructure"), ",")
+			if name == "-" {
+				return ""
+			}
+			if name == "" {
+				return field.Name
+			}
+			return name
+		})
+		_ = configValidate.RegisterValidation("oneofci", oneOfFold)
+	})
+	return configValidate
+}
+
+// oneOfFold oneofci规则，与oneof相同但忽略大小写，如日志级别"INFO"
+func oneOfFold(fl validator.FieldLevel) bool {
+	value := fl.Field().String()
+	for _, v := range strings.Fields(fl.Param()) {
+		if strings.EqualFold(value, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// Bind 把配置中key对应的值绑定到结构体T
+// 支持default标签设置默认值，time.Duration支持"30s"格式，数字按秒计算，并按validate标签校验，
+// 所有不合法的字段汇总为一个错误返回，配置热加载时同样按这些规则校验新配置
+func Bind[T any](conf Config, key string) (T, error) {
+	var res T
+	if r, ok := conf.(validatorRegistry); ok {
+		r.addValidator(key, func(c Config) error {
+			_, err := Bind[T](c, key)
+			return err
+		})
+	}
+	value := reflect.ValueOf(&res).Elem()
+	if value.Kind() != reflect.Struct {
+		return res, fmt.Errorf("配置%s绑定失败:仅支持结构体,当前类型为%T", key, res)
+	}
+	if b, ok := conf.(envBinder); ok {
+		for _, k := range settingKeys(value.Type(), key) {
+			b.bindEnv(k)
+		}
+	}
+	var errs []error
+	if err := setDefaults(value); err != nil {
+		errs = append(errs, err)
+	}
+	if input, ok := lookupSetting(conf.AllSettings(), key); ok {
+		if err := decodeSetting(input, &res); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := validateSetting(conf, key, res); err != nil {
+		errs = append(errs, err)
+	}
+	if len(errs) > 0 {
+		return res, fmt.Errorf("配置%s绑定失败:%w", key, errors.Join(errs...))
+	}
+	return res, nil
+}
+
+// envBinder 支持绑定环境变量的配置
+type envBinder interface {
+	bindEnv(key string)
+}
+
+// settingKeys 结构体各字段对应的配置key，嵌套结构体展开为"."分隔的key
+func settingKeys(t reflect.Type, prefix string) []string {
+	var res []string
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("mapstructure"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		key := prefix + "." + name
+		if field.Type.Kind() == reflect.Struct {
+			res = append(res, settingKeys(field.Type, key)...)
+			continue
+		}
+		res = append(res, key)
+	}
+	return res
+}
+
+// lookupSetting 按"."分隔的key查找配置
+func lookupSetting(settings map[string]any, key string) (any, bool) {
+	var res any = settings
+	for _, k := range strings.Split(strings.ToLower(key), ".") {
+		m, ok := res.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		if res, ok = m[k]; !ok {
+			return nil, false
+		}
+	}
+	return res, true
+}
+
+// decodeSetting 把配置解码到结构体，不存在的key保留原值
+func decodeSetting(input, output any) error {
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook: mapstructure.ComposeDecodeHookFunc(
+			secondsToDurationHook,
+			mapstructure.StringToTimeDurationHookFunc(),
+			stringToSliceHook,
+		),
+		WeaklyTypedInput: true,
+		Result:           output,
+	})
+	if err != nil {
+		return err
+	}
+	return decoder.Decode(input)
+}
+
+// secondsToDurationHook time.Duration字段的数字按秒计算，如30和"30"都表示30秒
+func secondsToDurationHook(f reflect.Type, t reflect.Type, data any) (any, error) {
+	if t != reflect.TypeOf(time.Duration(0)) {
+		return data, nil
+	}
+	switch f.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return time.Duration(reflect.ValueOf(data).Convert(reflect.TypeOf(int64(0))).Int()) * time.Second, nil
+	case reflect.Float32, reflect.Float64:
+		return time.Duration(reflect.ValueOf(data).Float() * float64(time.Second)), nil
+	case reflect.String:
+		if v, err := strconv.ParseFloat(strings.TrimSpace(data.(string)), 64); err == nil {
+			return time.Duration(v * float64(time.Second)), nil
+		}
+	}
+	return data, nil
+}
+
+// stringToSliceHook 把","分隔的字符串转换为切片，元素类型由mapstructure继续转换
+func stringToSliceHook(f reflect.Type, t reflect.Type, data any) (any, error) {
+	if f.Kind() != reflect.String || t.Kind() != reflect.Slice || t.Elem().Kind() == reflect.Uint8 {
+		return data, nil
+	}
+	raw := data.(string)
+	if raw == "" {
+		return []string{}, nil
+	}
+	return strings.Split(raw, ","), nil
+}
+
+// setDefaults 按default标签给零值字段设置默认值
+func setDefaults(value reflect.Value) error {
+	var errs []error
+	t := value.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		f := value.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		if f.Kind() == reflect.Struct {
+			if err := setDefaults(f); err != nil {
+				errs = append(errs, err)
+			}
+			continue
+		}
+		tag, ok := field.Tag.Lookup("default")
+		if !ok || !f.IsZero() {
+			continue
+		}
+		if err := decodeSetting(tag, f.Addr().Interface()); err != nil {
+			errs = append(errs, fmt.Errorf("字段%s默认值%q不合法:%v", field.Name, tag, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// validateSetting 按validate标签校验配置
+func validateSetting(conf Config, key string, value any) error {
+	err := getValidate().Struct(value)
+	var validationErrors validator.ValidationErrors
+	if !errors.As(err, &validationErrors) {
+		return err
+	}
+	errs := make([]error, 0, len(validationErrors))
+	for _, v := range validationErrors {
+		// Namespace以结构体类型名开头，替换为配置的key
+		_, field, _ := strings.Cut(v.Namespace(), ".")
+		rule := v.Tag()
+		if v.Param() != "" {
+			rule += "=" + v.Param()
+		}
+		path := key + "." + field
+		var current any = v.Value()
+		if conf.IsSecret(path) {
+			current = redacted
+		}
+		errs = append(errs, fmt.Errorf("%s 不满足校验规则 %s,当前值:%v", path, rule, current))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,43 @@
+package hopter
+
+import "testing"
+
+func TestBindLevelIgnoreCase(t *testing.T) {
+	conf := NewConfig("", "")
+	conf.Set("log.level", "INFO")
+	conf.Set("log.gorm.level", "Warn")
+	value, err := Bind[logConfig](conf, "log")
+	if err != nil {
+		t.Fatalf("Bind: %v", err)
+	}
+	if value.Level != "INFO" || value.Gorm.Level != "Warn" {
+		t.Fatalf("level = %q, gorm level = %q", value.Level, value.Gorm.Level)
+	}
+	conf.Set("log.level", "verbose")
+	if _, err := Bind[logConfig](conf, "log"); err == nil {
+		t.Fatal("Bind: expected error for unknown level")
+	}
+}
+
+func TestBindEnvOnlyKey(t *testing.T) {
+	t.Setenv("HOPTER_ADMIN_PORT", "9090")
+	t.Setenv("HOPTER_TRACE_ENABLE", "true")
+	conf := NewConfig("", "HOPTER")
+	admin, err := Bind[adminConfig](conf, "admin")
+	if err != nil {
+		t.Fatalf("Bind: %v", err)
+	}
+	if admin.Port != "9090" {
+		t.Fatalf("admin.port = %q, want 9090", admin.Port)
+	}
+	trace, err := Bind[traceConfig](conf, "trace")
+	if err != nil {
+		t.Fatalf("Bind: %v", err)
+	}
+	if !trace.Enable {
+		t.Fatal("trace.enable = false, want true")
+	}
+	if got := conf.Source("admin.port"); got != SourceEnv {
+		t.Fatalf("Source = %q, want %q", got, SourceEnv)
+	}
+}
@@ -0,0 +1,27 @@
+// keygen 生成session密钥
+//
+//	go run github.com/allposs/hopter/cmd/keygen -n 2
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	web "github.com/allposs/hopter"
+)
+
+func main() {
+	n := flag.Int("n", 1, "生成的密钥对数量")
+	flag.Parse()
+	fmt.Println("server:")
+	fmt.Println("  sessionKeys:")
+	for i := 0; i < *n; i++ {
+		hashKey, blockKey, err := web.GenerateSessionKeys()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "生成密钥异常:%v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("    - [\"%s\", \"%s\"]\n", hashKey, blockKey)
+	}
+}
@@ -1,16 +1,63 @@
 package hopter
 
 import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 )
 
+// envKeyReplacer 环境变量名中用"_"代替"."，如server.port对应PREFIX_SERVER_PORT
+var envKeyReplacer = strings.NewReplacer(".", "_")
+
+// sensitiveWords 配置输出时需要脱敏的字段关键字
+var sensitiveWords = []string{"key", "secret", "password", "token"}
+
+// redacted 脱敏后的值
+const redacted = "******"
+
 // config 配置
 type config struct {
 	*viper.Viper
+	// mu 热加载时保护viper的读写
+	mu sync.RWMutex
+	// validators Bind注册的校验函数，热加载时用于校验新配置
+	validators map[string]func(Config) error
+	// listeners 配置变更订阅
+	listeners []listener
+	// layers 配置文件层，依次为基础配置和环境配置
+	layers []configLayer
+	// prefix 环境变量前缀
+	prefix string
+	// overrides 通过Set设置的key
+	overrides map[string]bool
+	// flags 绑定的命令行参数
+	flags *pflag.FlagSet
+	// secrets 引用了密钥的key
+	secrets map[string]bool
+	// err 读取配置时密钥解析失败的错误
+	err error
+}
+
+// listener 配置变更订阅
+type listener struct {
+	key string
+	fn  func(old, new any)
 }
 
+// NewConfig 创建配置，配置优先级从低到高依次为:
+// 默认值、config.yaml、config.<env>.yaml、环境变量、命令行参数、Set
 func NewConfig(path, prefix string) *config {
-	res := &config{viper.New()}
+	res := &config{
+		Viper:      viper.New(),
+		validators: make(map[string]func(Config) error),
+		prefix:     prefix,
+		overrides:  make(map[string]bool),
+	}
 	if path != "" {
 		res.SetConfigFile(path)
 	} else {
@@ -24,6 +71,7 @@ func NewConfig(path, prefix string) *config {
 		res.AutomaticEnv()
 		// 环境变量前缀
 		res.SetEnvPrefix(prefix)
+		res.SetEnvKeyReplacer(envKeyReplacer)
 	}
 	// 设置默认值
 	res.SetDefault("server.port", 8000)
@@ -32,6 +80,7 @@ func NewConfig(path, prefix string) *config {
 	res.SetDefault("server.writeTimeout", 30)
 	res.SetDefault("server.idleTimeout", 30)
 	res.SetDefault("server.maxHeaderBytes", 16384)
+	res.SetDefault("server.shutdownTimeout", 30)
 	res.SetDefault("server.sessionKey", sessionKeyPairs)
 	res.SetDefault("log.level", "info")
 	res.SetDefault("log.path", "./logs/server.log")
@@ -40,62 +89,134 @@ func NewConfig(path, prefix string) *config {
 }
 
 // Get 用户获取配置信息
-func (c config) Get(str string) any {
+func (c *config) Get(str string) any {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
 	return c.Viper.Get(str)
 }
 
 // // Set 设置配置参数
 func (c *config) Set(str string, value any) Config {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	c.Viper.Set(str, value)
+	c.overrides[strings.ToLower(str)] = true
 	return c
 }
 
+// AllSettings 获取全部配置
+func (c *config) AllSettings() map[string]any {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.Viper.AllSettings()
+}
+
+// bindEnv 绑定key对应的环境变量，未出现在配置文件和默认值中的key也能从环境变量读取
+func (c *config) bindEnv(key string) {
+	if c.prefix == "" {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_ = c.Viper.BindEnv(key, c.envName(key))
+}
+
+// configErrorer 读取配置时可能失败的配置
+type configErrorer interface {
+	readErr() error
+}
+
+// readErr 读取配置的错误，密钥无法解析时服务不能启动
+func (c *config) readErr() error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.err
+}
+
 // Config 配置接口
 type Config interface {
 	Get(str string) any
 	UnmarshalKey(str string, value any, opts ...viper.DecoderConfigOption) error
 	ReadInConfig() Config
 	Set(str string, value any) Config
+	AllSettings() map[string]any
+	// OnChange 订阅配置变更，热加载后key对应的值变化时回调
+	OnChange(key string, fn func(old, new any))
+	// Watch 监听配置文件变化并热加载，ctx取消后停止
+	Watch(ctx context.Context) error
+	// Profile 当前配置环境
+	Profile() string
+	// BindFlags 绑定命令行参数
+	BindFlags(flags *pflag.FlagSet) error
+	// Source 获取key的值来自哪一层配置
+	Source(key string) string
+	// Redacted 获取脱敏后的全部配置
+	Redacted() map[string]any
+	// IsSecret key是否为密钥
+	IsSecret(key string) bool
 }
 
-// ReadInConfig 读取配置文件
+// ReadInConfig 读取配置文件，并合并server.env或HOPTER_ENV指定环境的配置文件
 func (c *config) ReadInConfig() Config {
-	if err := viper.ReadInConfig(); err != nil {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.Viper.ReadInConfig(); err != nil {
 		if _, ok := err.(viper.ConfigFileNotFoundError); !ok {
 			Warn("web服务启动异常:服务器解析配置文件异常，%v", err)
 		}
+		return c
+	}
+	files := []string{c.Viper.ConfigFileUsed()}
+	if profile := c.profile(); profile != "" {
+		file := profileFile(files[0], profile)
+		if isExist(file) {
+			files = append(files, file)
+		} else {
+			Warn("环境%s的配置文件%s不存在", profile, file)
+		}
+	}
+	layers, err := readLayers(files)
+	if err == nil {
+		err = c.applyLayers(layers)
+	}
+	var secretErr *secretError
+	if errors.As(err, &secretErr) {
+		c.err = err
+		return c
+	}
+	if err != nil {
+		Warn("web服务启动异常:服务器解析配置文件异常，%v", err)
 	}
 	return c
 }
 
 // UnmarshalKey 用于将配置文件中的特定key的值解析并映射到一个结构体（Struct）中
 func (c *config) UnmarshalKey(str string, value any, opts ...viper.DecoderConfigOption) error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
 	return c.Viper.UnmarshalKey(str, value, opts...)
 }
 
 // ginConfig 服务器配置
 type ginConfig struct {
-	ENV            string `yaml:"env"`
-	Port           string `yaml:"port"`
-	IP             string `yaml:"ip"`
-	ReadTimeout    int    `yaml:"readTimeout"`
-	WriteTimeout   int    `yaml:"writeTimeout"`
-	IdleTimeout    int    `yaml:"idleTimeout"`
-	MaxHeaderBytes int    `yaml:"maxHeaderBytes"`
-	SessionKey     string `yaml:"sessionKey"`
-}
-
-// defaultGinConfig 默认配置
-func defaultGinConfig() *ginConfig {
-	res := new(ginConfig)
-	res.IP = "0.0.0.0"
-	res.Port = "8080"
-	res.ReadTimeout = 30
-	res.WriteTimeout = 30
-	res.IdleTimeout = 30
-	res.MaxHeaderBytes = 16384
-	res.SessionKey = sessionKeyPairs
-	return res
+	ENV            string `mapstructure:"env"`
+	Port           string `mapstructure:"port" default:"8000" validate:"required,numeric"`
+	IP             string `mapstructure:"ip" default:"0.0.0.0" validate:"omitempty,ip|hostname"`
+	ReadTimeout    int    `mapstructure:"readTimeout" default:"30" validate:"min=0"`
+	WriteTimeout   int    `mapstructure:"writeTimeout" default:"30" validate:"min=0"`
+	IdleTimeout    int    `mapstructure:"idleTimeout" default:"30" validate:"min=0"`
+	MaxHeaderBytes int    `mapstructure:"maxHeaderBytes" default:"16384" validate:"min=0"`
+	SessionKey     string `mapstructure:"sessionKey"`
+	// session密钥列表，每项为[hashKey, blockKey]，第一项用于签发
+	SessionKeys [][]string `mapstructure:"sessionKeys"`
+	// 是否为debug模式，开启gin的debug模式并允许使用默认的session密钥
+	Debug bool `mapstructure:"debug"`
+	// 优雅关闭等待时间，如"30s"，数字按秒计算，0表示不限制
+	ShutdownTimeout time.Duration `mapstructure:"shutdownTimeout" default:"30s" validate:"min=0"`
+	// 单个bean初始化和关闭的超时时间，如"10s"，数字按秒计算，0表示不限制
+	BeanTimeout time.Duration `mapstructure:"beanTimeout" default:"10s" validate:"min=0"`
+	// TLS配置
+	TLS tlsConfig `mapstructure:"tls"`
 }
 
 // Endpoint 对外端点
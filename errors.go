@@ -0,0 +1,118 @@
+package hopter
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+)
+
+// 常用错误
+var (
+	ErrBadRequest   = NewError(http.StatusBadRequest, "BAD_REQUEST", "请求参数错误")
+	ErrUnauthorized = NewError(http.StatusUnauthorized, "UNAUTHORIZED", "未登录或登录已过期")
+	ErrForbidden    = NewError(http.StatusForbidden, "FORBIDDEN", "没有访问权限")
+	ErrNotFound     = NewError(http.StatusNotFound, "NOT_FOUND", "资源不存在")
+	ErrConflict     = NewError(http.StatusConflict, "CONFLICT", "资源冲突")
+	ErrInternal     = NewError(http.StatusInternalServerError, "INTERNAL_ERROR", "web服务异常,请联系管理人员")
+)
+
+// HTTPError 统一错误，包级函数Error已用于日志，因此不命名为Error
+type HTTPError struct {
+	// 业务错误码
+	Code string
+	// HTTP状态码
+	Status int
+	// 错误信息，返回给调用方
+	Message string
+	// 错误详情，返回给调用方
+	Details any
+	// 原始错误，只记录日志不返回给调用方
+	Cause error
+}
+
+// NewError 创建错误
+func NewError(status int, code, message string) *HTTPError {
+	return &HTTPError{Code: code, Status: status, Message: message}
+}
+
+// Error error接口实现
+func (e *HTTPError) Error() string {
+	if e.Cause != nil {
+		return fmt.Sprintf("%s: %s: %v", e.Code, e.Message, e.Cause)
+	}
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+// Unwrap 返回原始错误
+func (e *HTTPError) Unwrap() error {
+	return e.Cause
+}
+
+// Is 错误码相同即认为是同一错误，用于errors.Is(err, ErrNotFound)
+func (e *HTTPError) Is(target error) bool {
+	t, ok := target.(*HTTPError)
+	return ok && t.Code == e.Code
+}
+
+// WithMessage 返回替换错误信息后的副本
+func (e *HTTPError) WithMessage(format string, args ...any) *HTTPError {
+	res := *e
+	res.Message = fmt.Sprintf(format, args...)
+	return &res
+}
+
+// WithDetails 返回附带详情的副本
+func (e *HTTPError) WithDetails(details any) *HTTPError {
+	res := *e
+	res.Details = details
+	return &res
+}
+
+// Wrap 返回附带原始错误的副本
+func (e *HTTPError) Wrap(cause error) *HTTPError {
+	res := *e
+	res.Cause = cause
+	return &res
+}
+
+// ErrorBody 错误响应体
+type ErrorBody struct {
+	Code    string `json:"code" xml:"code" yaml:"code"`
+	Message string `json:"message" xml:"message" yaml:"message"`
+	Details any    `json:"details,omitempty" xml:"details,omitempty" yaml:"details,omitempty"`
+}
+
+// Send Message接口实现
+func (e *HTTPError) Send(Context) any {
+	return ErrorBody{Code: e.Code, Message: e.Message, Details: e.Details}
+}
+
+// StatusCode StatusMessage接口实现
+func (e *HTTPError) StatusCode() int {
+	return e.Status
+}
+
+// asError 转换为统一错误，未知错误转换为ErrInternal并记录堆栈
+func asError(ctx *Context, err error) *HTTPError {
+	var res *HTTPError
+	if errors.As(err, &res) {
+		if res.Status >= http.StatusInternalServerError {
+			ctx.Logger().Errorf("web服务异常:%v", res)
+		}
+		return res
+	}
+	ctx.Logger().Errorf("web服务异常:%v\n%s", err, debug.Stack())
+	return ErrInternal.Wrap(err)
+}
+
+// Fail 返回错误响应，*HTTPError按其状态码返回，其他错误返回500
+func (ctx *Context) Fail(err error) Message {
+	return asError(ctx, err)
+}
+
+// abortWithError 中止请求并返回错误响应
+func abortWithError(ctx *Context, err error) {
+	ctx.Abort()
+	render(ctx, asError(ctx, err))
+}
@@ -0,0 +1,76 @@
+package hopter
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// denyMiddleware 拒绝带deny头的请求
+type denyMiddleware struct{}
+
+func (denyMiddleware) Handler(ctx *Context) error {
+	if ctx.GetHeader("deny") != "" {
+		return ErrForbidden.WithMessage("拒绝访问:%s", ctx.GetHeader("deny"))
+	}
+	return nil
+}
+
+func (denyMiddleware) OnInject() any {
+	return nil
+}
+
+func TestErrorEnvelope(t *testing.T) {
+	e := newTestEngine()
+	e.engine.Use(recovered())
+	e.Attach(denyMiddleware{})
+	e.Handle("GET", "/panic", func(*Context) Message {
+		panic("boom")
+	})
+	e.Handle("GET", "/unknown", func(ctx *Context) Message {
+		return ctx.Fail(errors.New("db down"))
+	})
+	e.Handle("GET", "/missing", func(ctx *Context) Message {
+		return ctx.Fail(ErrNotFound.WithDetails(map[string]string{"id": "1"}))
+	})
+	tests := []struct {
+		name    string
+		path    string
+		deny    string
+		status  int
+		body    ErrorBody
+		details bool
+	}{
+		{"middleware", "/missing", "admin", http.StatusForbidden, ErrorBody{Code: "FORBIDDEN", Message: "拒绝访问:admin"}, false},
+		{"panic", "/panic", "", http.StatusInternalServerError, ErrorBody{Code: "INTERNAL_ERROR", Message: ErrInternal.Message}, false},
+		{"unknown", "/unknown", "", http.StatusInternalServerError, ErrorBody{Code: "INTERNAL_ERROR", Message: ErrInternal.Message}, false},
+		{"http error", "/missing", "", http.StatusNotFound, ErrorBody{Code: "NOT_FOUND", Message: ErrNotFound.Message}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tt.path, nil)
+			if tt.deny != "" {
+				req.Header.Set("deny", tt.deny)
+			}
+			w := httptest.NewRecorder()
+			e.engine.ServeHTTP(w, req)
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d", w.Code, tt.status)
+			}
+			var body ErrorBody
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatalf("body %q: %v", w.Body.String(), err)
+			}
+			if body.Code != tt.body.Code || body.Message != tt.body.Message || (body.Details != nil) != tt.details {
+				t.Fatalf("body = %+v, want %+v", body, tt.body)
+			}
+			// 原始错误只记录日志，不返回给调用方
+			if strings.Contains(w.Body.String(), "boom") || strings.Contains(w.Body.String(), "db down") {
+				t.Fatalf("body leaks cause: %s", w.Body.String())
+			}
+		})
+	}
+}
@@ -6,5 +6,7 @@ import (
 
 func main() {
 	config := web.NewConfig("", "HOPTER")
-	web.New(config, "", "").Attach().Run()
+	if err := web.New(config, "", "").Attach().Run(); err != nil {
+		web.Error("%v", err)
+	}
 }
@@ -0,0 +1,205 @@
+package hopter
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/allposs/hopter/metric"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"gorm.io/gorm/utils"
+)
+
+const (
+	metricGormQueryTotal    = "gorm_query_total"
+	metricGormQueryDuration = "gorm_query_duration"
+	// SourceField 未开启记录文件名和行号时，gorm日志中调用位置的字段名
+	SourceField = "source"
+)
+
+// gormTablePattern 从SQL中提取表名
+var gormTablePattern = regexp.MustCompile("(?i)\\b(?:FROM|INTO|UPDATE|JOIN)\\s+[`\"\\[]?([\\w.]+)")
+
+// gormLogConfig gorm日志配置参数
+type gormLogConfig struct {
+	// 日志级别 silent|error|warn|info，不区分大小写
+	Level string `mapstructure:"level" default:"warn" validate:"oneofci=silent error warn info"`
+	// 慢查询阈值，如"200ms"，0表示不记录慢查询
+	SlowThreshold time.Duration `mapstructure:"slowThreshold" default:"200ms"`
+	// 是否忽略ErrRecordNotFound错误
+	IgnoreRecordNotFound bool `mapstructure:"ignoreRecordNotFound" default:"true"`
+	// 是否只记录带占位符的SQL，不记录参数值
+	ParameterizedQueries bool `mapstructure:"parameterizedQueries"`
+	// 是否记录查询次数和耗时指标
+	Metrics bool `mapstructure:"metrics" default:"true"`
+}
+
+// gormLevels 配置中的级别
+var gormLevels = map[string]logger.LogLevel{
+	"silent": logger.Silent,
+	"error":  logger.Error,
+	"warn":   logger.Warn,
+	"info":   logger.Info,
+}
+
+// initGorm 设置gorm日志参数并注册查询指标
+func (l *Klogger) initGorm(option gormLogConfig) {
+	l.gorm = option
+	l.gormLevel = gormLevels[strings.ToLower(option.Level)]
+	if !option.Metrics {
+		return
+	}
+	m := metric.GetMonitor()
+	// 重复注册时返回错误，忽略即可
+	_ = m.AddMetric(&metric.Metric{
+		Type:        metric.Counter,
+		Name:        metricGormQueryTotal,
+		Description: "all the gorm query num.",
+		Labels:      []string{"table", "operation", "status"},
+	})
+	_ = m.AddMetric(&metric.Metric{
+		Type:        metric.Histogram,
+		Name:        metricGormQueryDuration,
+		Description: "the time gorm query spent, in seconds.",
+		Labels:      []string{"table", "operation"},
+		Buckets:     []float64{0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5},
+	})
+}
+
+// gormLogger gorm日志适配器，按log.gorm.level过滤日志，Klogger自身的方法不受影响
+type gormLogger struct {
+	log   *Klogger
+	level logger.LogLevel
+}
+
+// Gorm 得到gorm日志适配器，日志级别为log.gorm.level，可被LogMode覆盖
+func (l *Klogger) Gorm() logger.Interface {
+	return &gormLogger{log: l, level: l.gormLevel}
+}
+
+// LogMode logger接口实现，返回指定级别的gorm日志适配器
+func (l *Klogger) LogMode(level logger.LogLevel) logger.Interface {
+	return &gormLogger{log: l, level: level}
+}
+
+// ParamsFilter gorm参数过滤，开启parameterizedQueries时不记录参数值
+func (l *Klogger) ParamsFilter(_ context.Context, sql string, params ...any) (string, []any) {
+	if l.gorm.ParameterizedQueries {
+		return sql, nil
+	}
+	return sql, params
+}
+
+// Trace gorm的SQL日志，按log.gorm.level过滤
+func (l *Klogger) Trace(ctx context.Context, begin time.Time, fc func() (sql string, rowsAffected int64), err error) {
+	l.Gorm().Trace(ctx, begin, fc, err)
+}
+
+// LogMode logger接口实现，返回指定级别的副本
+func (l *gormLogger) LogMode(level logger.LogLevel) logger.Interface {
+	res := *l
+	res.level = level
+	return &res
+}
+
+// Info Info级别日志写入，级别低于info时忽略
+func (l *gormLogger) Info(ctx context.Context, message string, args ...any) {
+	if l.level >= logger.Info {
+		l.log.WithContext(ctx).Infof(message, args...)
+	}
+}
+
+// Warn Warn级别日志写入，级别低于warn时忽略
+func (l *gormLogger) Warn(ctx context.Context, message string, args ...any) {
+	if l.level >= logger.Warn {
+		l.log.WithContext(ctx).Warnf(message, args...)
+	}
+}
+
+// Error Error级别日志写入，级别低于error时忽略
+func (l *gormLogger) Error(ctx context.Context, message string, args ...any) {
+	if l.level >= logger.Error {
+		l.log.WithContext(ctx).Errorf(message, args...)
+	}
+}
+
+// ParamsFilter gorm参数过滤，开启parameterizedQueries时不记录参数值
+func (l *gormLogger) ParamsFilter(ctx context.Context, sql string, params ...any) (string, []any) {
+	return l.log.ParamsFilter(ctx, sql, params...)
+}
+
+// Trace gorm的SQL日志，错误记为error，慢查询记为warn，其他记为info
+func (l *gormLogger) Trace(ctx context.Context, begin time.Time, fc func() (sql string, rowsAffected int64), err error) {
+	option := l.log.gorm
+	elapsed := time.Since(begin)
+	notFound := errors.Is(err, gorm.ErrRecordNotFound)
+	slow := option.SlowThreshold > 0 && elapsed > option.SlowThreshold
+	failed := err != nil && !(notFound && option.IgnoreRecordNotFound)
+	var logged bool
+	switch {
+	case l.level <= logger.Silent:
+	case failed:
+		logged = l.level >= logger.Error
+	case slow:
+		logged = l.level >= logger.Warn
+	default:
+		logged = l.level >= logger.Info
+	}
+	if !logged && !option.Metrics {
+		return
+	}
+	sql, rows := fc()
+	if option.Metrics {
+		observeQuery(ctx, sql, elapsed, err != nil && !notFound)
+	}
+	if !logged {
+		return
+	}
+	fields := logrus.Fields{
+		"elapsed": float64(elapsed.Microseconds()) / 1000,
+		"rows":    rows,
+	}
+	// 开启记录文件名和行号时调用位置已写入fileInfoField
+	if !l.log.enableRecordFileInfo {
+		fields[SourceField] = utils.FileWithLineNum()
+	}
+	entry := l.log.WithContext(ctx).WithFields(fields)
+	switch {
+	case failed:
+		entry.WithError(err).Errorf("%s [%s]", sql, elapsed)
+	case slow:
+		entry.Warnf("慢查询,超过%s:%s [%s]", option.SlowThreshold, sql, elapsed)
+	default:
+		entry.Infof("%s [%s]", sql, elapsed)
+	}
+}
+
+// observeQuery 记录查询次数和耗时，耗时带有trace ID的exemplar
+func observeQuery(ctx context.Context, sql string, elapsed time.Duration, failed bool) {
+	table, operation := parseSQL(sql)
+	status := "ok"
+	if failed {
+		status = "error"
+	}
+	m := metric.GetMonitor()
+	_ = m.GetMetric(metricGormQueryTotal).Inc([]string{table, operation, status})
+	_ = m.GetMetric(metricGormQueryDuration).ObserveWithExemplar([]string{table, operation}, elapsed.Seconds(), contextExemplar(ctx))
+}
+
+// parseSQL 得到SQL的表名和操作类型
+func parseSQL(sql string) (table, operation string) {
+	sql = strings.TrimSpace(sql)
+	operation = "other"
+	if i := strings.IndexFunc(sql, func(r rune) bool { return r == ' ' || r == '\n' || r == '\t' }); i > 0 {
+		operation = strings.ToLower(sql[:i])
+	}
+	table = "unknown"
+	if m := gormTablePattern.FindStringSubmatch(sql); m != nil {
+		table = m[1]
+	}
+	return table, operation
+}
@@ -0,0 +1,59 @@
+package hopter
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm/logger"
+)
+
+// newTestKlogger 输出到buf的日志，gorm级别为warn
+func newTestKlogger(buf *bytes.Buffer) *Klogger {
+	log := logrus.New()
+	log.SetOutput(buf)
+	log.SetLevel(logrus.DebugLevel)
+	l := &Klogger{Logger: log}
+	l.initGorm(gormLogConfig{Level: "WARN", SlowThreshold: time.Second})
+	return l
+}
+
+func TestKloggerNotGated(t *testing.T) {
+	var buf bytes.Buffer
+	l := newTestKlogger(&buf)
+	l.Info(context.Background(), "application info")
+	if !strings.Contains(buf.String(), "application info") {
+		t.Fatalf("Klogger.Info was filtered by gorm level: %q", buf.String())
+	}
+}
+
+func TestGormLogMode(t *testing.T) {
+	var buf bytes.Buffer
+	l := newTestKlogger(&buf)
+	gormLog := l.Gorm()
+	gormLog.Info(context.Background(), "gorm info")
+	if buf.Len() > 0 {
+		t.Fatalf("gorm info logged at warn level: %q", buf.String())
+	}
+	verbose := gormLog.LogMode(logger.Info)
+	verbose.Info(context.Background(), "verbose info")
+	if !strings.Contains(buf.String(), "verbose info") {
+		t.Fatal("LogMode(Info) did not log info")
+	}
+	buf.Reset()
+	// LogMode返回副本，不影响原来的级别
+	gormLog.Info(context.Background(), "gorm info")
+	if buf.Len() > 0 {
+		t.Fatal("LogMode changed the original logger")
+	}
+	gormLog.Trace(context.Background(), time.Now(), func() (string, int64) {
+		return "SELECT * FROM users", 0
+	}, errors.New("bad connection"))
+	if !strings.Contains(buf.String(), "SELECT * FROM users") {
+		t.Fatalf("failed query was not logged: %q", buf.String())
+	}
+}
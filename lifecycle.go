@@ -0,0 +1,127 @@
+package hopter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Initializer bean初始化接口，注入完成后按依赖顺序调用，返回错误时拒绝启动
+type Initializer interface {
+	Initialize(ctx context.Context) error
+}
+
+// Closer bean关闭接口，Engine.Shutdown时按初始化的逆序调用
+type Closer interface {
+	Close(ctx context.Context) error
+}
+
+// Timeout 为bean的初始化和关闭单独指定超时时间，默认使用server.beanTimeout
+func Timeout(timeout time.Duration, bean any) *BeanDefinition {
+	d := define(bean)
+	d.timeout = timeout
+	return d
+}
+
+// ordered 按依赖顺序排列已创建的单例bean，被依赖的在前，字段间的循环引用按注册顺序处理
+func (b *BeanFactory) ordered() []*BeanDefinition {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	byName := make(map[string]*BeanDefinition, len(b.beans))
+	for _, d := range b.beans {
+		byName[d.Name()] = d
+	}
+	res := make([]*BeanDefinition, 0, len(b.beans))
+	visited := make(map[*BeanDefinition]bool)
+	var visit func(d *BeanDefinition)
+	visit = func(d *BeanDefinition) {
+		if visited[d] {
+			return
+		}
+		visited[d] = true
+		deps := b.graph[d.Name()]
+		paths := make([]string, 0, len(deps))
+		for path := range deps {
+			paths = append(paths, path)
+		}
+		sort.Strings(paths)
+		for _, path := range paths {
+			for _, name := range deps[path] {
+				if dep, ok := byName[name]; ok {
+					visit(dep)
+				}
+			}
+		}
+		if d.created && !d.prototype {
+			res = append(res, d)
+		}
+	}
+	for _, d := range b.beans {
+		visit(d)
+	}
+	return res
+}
+
+// initialize 按依赖顺序初始化bean，遇到错误停止，返回需要关闭的bean
+func (b *BeanFactory) initialize(ctx context.Context, timeout time.Duration) ([]*BeanDefinition, error) {
+	started := make([]*BeanDefinition, 0)
+	for _, d := range b.ordered() {
+		if v, ok := d.value.(Initializer); ok {
+			if err := d.call(ctx, timeout, v.Initialize); err != nil {
+				return started, fmt.Errorf("bean %s初始化失败:%w", d.Name(), err)
+			}
+		}
+		started = append(started, d)
+	}
+	return started, nil
+}
+
+// close 按初始化的逆序关闭bean，单个bean失败不影响其他bean，汇总所有错误
+func (b *BeanFactory) close(ctx context.Context, started []*BeanDefinition, timeout time.Duration) error {
+	var errs []error
+	for i := len(started) - 1; i >= 0; i-- {
+		d := started[i]
+		if v, ok := d.value.(Closer); ok {
+			if err := d.call(ctx, timeout, v.Close); err != nil {
+				errs = append(errs, fmt.Errorf("bean %s关闭失败:%w", d.Name(), err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// call 在超时时间内执行生命周期方法，超时后不再等待
+func (d *BeanDefinition) call(ctx context.Context, timeout time.Duration, fn func(ctx context.Context) error) error {
+	if d.timeout > 0 {
+		timeout = d.timeout
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- fn(ctx)
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("超时,%w", ctx.Err())
+	}
+}
+
+// initBeans 初始化bean，并注册关闭钩子，钩子在服务的Stopper之后执行
+func (e *Engine) initBeans(ctx context.Context) error {
+	started, err := e.beanFactory.initialize(ctx, e.beanTimeout)
+	e.OnShutdown(func(ctx context.Context) error {
+		return e.beanFactory.close(ctx, started, e.beanTimeout)
+	})
+	if err != nil {
+		return fmt.Errorf("web服务启动失败:%w", err)
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package hopter
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// lifecycleLog 记录生命周期方法的调用顺序
+type lifecycleLog struct {
+	events []string
+}
+
+type (
+	lcDB struct {
+		log *lifecycleLog
+		err error
+	}
+	lcRepo struct {
+		DB  *lcDB `inject:""`
+		log *lifecycleLog
+		err error
+	}
+	lcSvc struct {
+		Repo *lcRepo `inject:""`
+		log  *lifecycleLog
+	}
+	lcSlow struct{}
+)
+
+func (d *lcDB) Initialize(context.Context) error {
+	d.log.events = append(d.log.events, "init:db")
+	return nil
+}
+
+func (d *lcDB) Close(context.Context) error {
+	d.log.events = append(d.log.events, "close:db")
+	return d.err
+}
+
+func (r *lcRepo) Initialize(context.Context) error {
+	r.log.events = append(r.log.events, "init:repo")
+	return nil
+}
+
+func (r *lcRepo) Close(context.Context) error {
+	r.log.events = append(r.log.events, "close:repo")
+	return r.err
+}
+
+func (s *lcSvc) Initialize(context.Context) error {
+	s.log.events = append(s.log.events, "init:svc")
+	return nil
+}
+
+func (s *lcSvc) Close(context.Context) error {
+	s.log.events = append(s.log.events, "close:svc")
+	return nil
+}
+
+func (*lcSlow) Initialize(ctx context.Context) error {
+	<-ctx.Done()
+	// 超时后不再等待，返回值被忽略
+	time.Sleep(time.Second)
+	return nil
+}
+
+func TestLifecycleOrder(t *testing.T) {
+	log := &lifecycleLog{}
+	b := NewBeanFactory()
+	// 注册顺序与依赖顺序相反
+	b.set(&lcSvc{log: log}, &lcRepo{log: log}, &lcDB{log: log})
+	if err := b.refresh(); err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+	started, err := b.initialize(context.Background(), 0)
+	if err != nil {
+		t.Fatalf("initialize: %v", err)
+	}
+	if err := b.close(context.Background(), started, 0); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	want := "init:db,init:repo,init:svc,close:svc,close:repo,close:db"
+	if got := strings.Join(log.events, ","); got != want {
+		t.Fatalf("events = %s, want %s", got, want)
+	}
+}
+
+func TestLifecycleTimeout(t *testing.T) {
+	b := NewBeanFactory()
+	b.set(Timeout(20*time.Millisecond, &lcSlow{}))
+	if err := b.refresh(); err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+	start := time.Now()
+	// bean单独指定的超时时间优先于默认值
+	_, err := b.initialize(context.Background(), time.Minute)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("initialize = %v, want DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("initialize waited %v", elapsed)
+	}
+}
+
+func TestLifecycleCloseErrors(t *testing.T) {
+	log := &lifecycleLog{}
+	b := NewBeanFactory()
+	b.set(&lcDB{log: log, err: errors.New("db closed")}, &lcRepo{log: log, err: errors.New("repo closed")}, &lcSvc{log: log})
+	if err := b.refresh(); err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+	started, err := b.initialize(context.Background(), 0)
+	if err != nil {
+		t.Fatalf("initialize: %v", err)
+	}
+	err = b.close(context.Background(), started, 0)
+	if err == nil || !strings.Contains(err.Error(), "db closed") || !strings.Contains(err.Error(), "repo closed") {
+		t.Fatalf("close = %v, want both errors", err)
+	}
+	// 单个bean关闭失败不影响其他bean
+	if got := strings.Join(log.events[3:], ","); got != "close:svc,close:repo,close:db" {
+		t.Fatalf("close events = %s", got)
+	}
+}
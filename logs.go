@@ -2,13 +2,17 @@ package hopter
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"reflect"
+	"runtime"
+	"runtime/debug"
+	"strings"
 	"time"
 
-	"github.com/gin-gonic/gin"
 	rotatelogs "github.com/lestrrat-go/file-rotatelogs"
 	"github.com/rifflock/lfshook"
 	"github.com/sirupsen/logrus"
@@ -26,6 +30,8 @@ const (
 	JSON = "json"
 	//DataKey json日志条目中 数据字段都会作为该字段的嵌入字段
 	DataKey = "data"
+	//FuncField 函数名字段名
+	FuncField = "func"
 )
 
 var (
@@ -37,38 +43,49 @@ var (
 
 // logConfig 日志配置参数
 type logConfig struct {
-	// 日志级别
-	Level string `yaml:"level"`
+	// 日志级别，不区分大小写
+	Level string `mapstructure:"level" default:"info" validate:"oneofci=trace debug info warn warning error fatal panic"`
 	// log 路径
-	Path string `yaml:"path"`
+	Path string `mapstructure:"path" default:"./logs/server.log"`
 	// 日志类型 json|text
-	Type string `yaml:"type"`
+	Type string `mapstructure:"type" default:"text" validate:"oneof=text json"`
 	//是否不同类型分文件存储
-	IsClassSubFile bool `yaml:"isClassSubFile"`
+	IsClassSubFile bool `mapstructure:"isClassSubFile"`
 	// 文件名的日期格式
-	FileNameDateFormat string `yaml:"fileNameDateFormat"`
+	FileNameDateFormat string `mapstructure:"fileNameDateFormat"`
 	// 是否前台打印日志
-	IsForeground bool `yaml:"isForeground"`
+	IsForeground bool `mapstructure:"isForeground"`
 	// 日志中日期时间格式
-	TimestampFormat string `yaml:"timestampFormat"`
-	// 日志最长保存多久
-	MaxAge time.Duration `yaml:"maxAge"`
-	// 日志默认多长时间轮转一次
-	RotationTime time.Duration `yaml:"rotationTime"`
+	TimestampFormat string `mapstructure:"timestampFormat"`
+	// 日志最长保存多久，如"168h"
+	MaxAge time.Duration `mapstructure:"maxAge"`
+	// 日志默认多长时间轮转一次，如"24h"
+	RotationTime time.Duration `mapstructure:"rotationTime"`
 	// 是否开启记录文件名和行号
-	IsEnableRecordFileInfo bool `yaml:"isEnableRecordFileInfo"`
+	IsEnableRecordFileInfo bool `mapstructure:"isEnableRecordFileInfo"`
 	// 文件名和行号字段名
-	FileInfoField string `yaml:"fileInfoField"`
+	FileInfoField string `mapstructure:"fileInfoField" default:"caller"`
+	// 是否记录文件的完整路径，否则只记录文件名
+	IsFileInfoFullPath bool `mapstructure:"isFileInfoFullPath"`
+	// 是否同时记录函数名
+	IsRecordFuncName bool `mapstructure:"isRecordFuncName"`
 	// json日志是否美化输出
-	JSONPrettyPrint bool `yaml:"jsonPrettyPrint"`
+	JSONPrettyPrint bool `mapstructure:"jsonPrettyPrint"`
 	// json日志条目中 数据字段都会作为该字段的嵌入字段
-	JSONDataKey string `json:"jsonDataKey"`
+	JSONDataKey string `mapstructure:"jsonDataKey"`
+	// gorm日志
+	Gorm gormLogConfig `mapstructure:"gorm"`
 }
 
 // Klogger 日志引擎
 type Klogger struct {
 	*logrus.Logger
 	enableRecordFileInfo bool
+	// writers 日志文件写入器，关闭时统一释放
+	writers []io.Closer
+	// gorm gorm日志参数，gormLevel为配置的gorm日志级别
+	gorm      gormLogConfig
+	gormLevel logger.LogLevel
 }
 
 func newLogger(option *logConfig) (*logrus.Logger, error) {
@@ -101,6 +118,15 @@ func newLogger(option *logConfig) (*logrus.Logger, error) {
 		level = logrus.InfoLevel
 	}
 	log.SetLevel(level)
+	// 先于文件hook执行，文件和前台输出都带有trace ID和调用位置
+	log.AddHook(traceHook{})
+	if option.IsEnableRecordFileInfo {
+		log.AddHook(&callerHook{
+			field:    option.FileInfoField,
+			fullPath: option.IsFileInfoFullPath,
+			funcName: option.IsRecordFuncName,
+		})
+	}
 	switch option.Type {
 	case JSON:
 		format := &logrus.JSONFormatter{
@@ -119,6 +145,90 @@ func newLogger(option *logConfig) (*logrus.Logger, error) {
 	return log, nil
 }
 
+// skippedPackages 查找调用位置时跳过的包，包括日志库、gin、gorm和hopter自身
+var skippedPackages = []string{
+	"github.com/sirupsen/logrus.",
+	"github.com/rifflock/lfshook.",
+	"github.com/gin-gonic/",
+	"gorm.io/",
+	reflect.TypeOf(Klogger{}).PkgPath() + ".",
+}
+
+// callerHook 记录调用日志的文件名和行号
+type callerHook struct {
+	field    string
+	fullPath bool
+	funcName bool
+}
+
+func (h *callerHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (h *callerHook) Fire(entry *logrus.Entry) error {
+	frame, ok := callerFrame()
+	if !ok {
+		return nil
+	}
+	file := frame.File
+	if !h.fullPath {
+		file = filepath.Base(file)
+	}
+	entry.Data[h.field] = fmt.Sprintf("%s:%d", file, frame.Line)
+	if h.funcName {
+		entry.Data[FuncField] = frame.Function
+	}
+	return nil
+}
+
+// callerFrame 得到第一个业务代码的栈帧，跳过skippedPackages和标准库
+// 访问日志等由框架自身产生的日志没有业务代码的栈帧，返回false，不记录调用位置
+func callerFrame() (runtime.Frame, bool) {
+	pcs := make([]uintptr, 32)
+	// 跳过runtime.Callers、callerFrame和Fire
+	n := runtime.Callers(3, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		frame, more := frames.Next()
+		if !skippedFrame(frame.Function) && !stdFrame(frame.Function) {
+			return frame, true
+		}
+		if !more {
+			return runtime.Frame{}, false
+		}
+	}
+}
+
+func skippedFrame(function string) bool {
+	for _, p := range skippedPackages {
+		if strings.HasPrefix(function, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// mainModule 主模块路径的第一段，模块名不含"."时用于和标准库区分
+var mainModule = func() string {
+	if info, ok := debug.ReadBuildInfo(); ok {
+		first, _, _ := strings.Cut(info.Main.Path, "/")
+		return first
+	}
+	return ""
+}()
+
+// stdFrame 是否为标准库的栈帧，标准库包路径的第一段不含"."
+func stdFrame(function string) bool {
+	if function == "" {
+		return true
+	}
+	first, _, ok := strings.Cut(function, "/")
+	if !ok {
+		first, _, _ = strings.Cut(function, ".")
+	}
+	return !strings.Contains(first, ".") && first != "main" && first != mainModule
+}
+
 // integrate 返回Logger
 // 日志类型是: 普通文本日志|JSON日志 全部级别都写入到同一个文件
 func integrate(option *logConfig) (*Klogger, error) {
@@ -160,8 +270,9 @@ func integrate(option *logConfig) (*Klogger, error) {
 
 	log.Hooks.Add(fileHook)
 	logs = &Klogger{
-		log,
-		option.IsEnableRecordFileInfo,
+		Logger:               log,
+		enableRecordFileInfo: option.IsEnableRecordFileInfo,
+		writers:              []io.Closer{writer},
 	}
 	return logs, nil
 }
@@ -243,38 +354,56 @@ func separate(option *logConfig) (*Klogger, error) {
 
 	log.Hooks.Add(fileHook)
 	logs = &Klogger{
-		log,
-		option.IsEnableRecordFileInfo,
+		Logger:               log,
+		enableRecordFileInfo: option.IsEnableRecordFileInfo,
+		writers:              []io.Closer{debugWriter, infoWriter, warnWriter, errorWriter, fatalWriter, panicWriter},
 	}
 	return logs, nil
 }
 
-func defaultLogConfig() *logConfig {
-	res := new(logConfig)
-	res.Level = "info"
-	res.Path = "./logs/server.log"
-	res.Type = Text
-	return res
-}
-
 // initLog 初始化日志
 func initLog(option Config) (*Klogger, error) {
-	value := defaultLogConfig()
-	if conf := option.Get("log"); conf != nil {
-		if err := option.UnmarshalKey("log", value); err != nil {
-			return nil, err
-		}
+	value, err := Bind[logConfig](option, "log")
+	if err != nil {
+		return nil, err
 	}
+	value.Level = strings.ToLower(value.Level)
 	Level = value.Level
+	var res *Klogger
 	if value.IsClassSubFile {
-		return separate(value)
+		res, err = separate(&value)
+	} else {
+		res, err = integrate(&value)
+	}
+	if err != nil {
+		return nil, err
 	}
-	return integrate(value)
+	res.initGorm(value.Gorm)
+	return res, nil
 }
 
-// LogMode logger接口实现
-func (l *Klogger) LogMode(logger.LogLevel) logger.Interface {
-	return l
+// watchLevel 配置中log.level变化时调整日志级别
+func (l *Klogger) watchLevel(conf Config) {
+	conf.OnChange("log.level", func(_, value any) {
+		level, err := logrus.ParseLevel(fmt.Sprint(value))
+		if err != nil {
+			l.Warnf("log.level配置不合法:%v", err)
+			return
+		}
+		l.SetLevel(level)
+		l.Infof("日志级别已调整为%s", level)
+	})
+}
+
+// Close 刷新并关闭日志文件
+func (l *Klogger) Close() error {
+	var errs []error
+	for _, w := range l.writers {
+		if err := w.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
 }
 
 // Debug Debug级别日志写入
@@ -307,26 +436,6 @@ func (l *Klogger) Panic(ctx context.Context, message string, args ...interface{}
 	l.WithContext(ctx).Panicf(message, args...)
 }
 
-// Trace Trace级别日志写入
-func (l *Klogger) Trace(ctx context.Context, begin time.Time, fc func() (sql string, rowsAffected int64), err error) {
-	elapsed := time.Since(begin)
-	sql, _ := fc()
-	fields := logrus.Fields{}
-	//if l.SourceField != "" {
-	//	fields[l.SourceField] = utils.FileWithLineNum()
-	//}
-	//if err != nil && !(errors.Is(err, gorm.ErrRecordNotFound) && l.SkipErrRecordNotFound) {
-	//	fields[logs.ErrorKey] = err
-	//	l.log.WithContext(ctx).WithFields(fields).Errorf("%s [%s]", sql, elapsed)
-	//	return
-	//}
-	//if l.SlowThreshold != 0 && elapsed > l.SlowThreshold {
-	//	l.log.WithContext(ctx).WithFields(fields).Warnf("%s [%s]", sql, elapsed)
-	//	return
-	//}
-	l.WithContext(ctx).WithFields(fields).Infof("%s [%s]", sql, elapsed)
-}
-
 // Debug Debug级别日志写入
 func Debug(message string, args ...any) {
 	logs.Debugf(message, args...)
@@ -376,40 +485,3 @@ func Panic(message string, args ...any) {
 	}
 	logs.Panicf(message, args...)
 }
-
-// LogMiddleware 日志插件
-func LogMiddleware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		c.Next()
-		//开始时间
-		startTime := time.Now()
-		//结束时间
-		endTime := time.Now()
-		//执行时间
-		latencyTime := endTime.Sub(startTime)
-		//请求方式
-		reqMethod := c.Request.Method
-		//请求路由
-		reqURI := c.Request.RequestURI
-		// 状态码
-		statusCode := c.Writer.Status()
-		//请求ip
-		clientIP := c.ClientIP()
-		//请求参数
-		reqParams := c.Request.Body
-		//请求ua
-		reqUa := c.Request.UserAgent()
-		var resultBody logrus.Fields
-		resultBody = make(map[string]interface{})
-		resultBody["requestUri"] = reqURI
-		resultBody["clientIp"] = clientIP
-		resultBody["body"] = reqParams
-		resultBody["userAgent"] = reqUa
-		resultBody["requestMethod"] = reqMethod
-		resultBody["startTime"] = startTime
-		resultBody["endTime"] = endTime
-		resultBody["latencyTime"] = latencyTime
-		resultBody["statusCode"] = statusCode
-		logs.WithFields(resultBody).Info()
-	}
-}
@@ -0,0 +1,27 @@
+package hopter_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	web "github.com/allposs/hopter"
+	"github.com/sirupsen/logrus"
+)
+
+func TestCallerUserFrame(t *testing.T) {
+	var buf bytes.Buffer
+	conf := web.NewConfig("", "")
+	conf.Set("log.isEnableRecordFileInfo", true)
+	conf.Set("log.path", t.TempDir()+"/server.log")
+	conf.Set("log.gorm.metrics", false)
+	e := web.New(conf, "", "")
+	defer e.Shutdown(context.Background())
+	e.Endpoint.Logs().SetOutput(&buf)
+	e.Endpoint.Logs().SetFormatter(&logrus.TextFormatter{DisableTimestamp: true})
+	web.Info("from user code")
+	if !strings.Contains(buf.String(), "logs_caller_test.go:") {
+		t.Fatalf("caller missing or wrong: %q", buf.String())
+	}
+}
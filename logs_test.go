@@ -0,0 +1,51 @@
+package hopter
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+func TestCallerOmittedForFramework(t *testing.T) {
+	var buf bytes.Buffer
+	log := logrus.New()
+	log.SetOutput(&buf)
+	log.AddHook(&callerHook{field: "caller"})
+	r := gin.New()
+	r.Use(newAccessLogFor(log))
+	r.GET("/", func(ctx *gin.Context) { ctx.Status(http.StatusOK) })
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	if !strings.Contains(buf.String(), "access") {
+		t.Fatalf("access log missing: %q", buf.String())
+	}
+	if strings.Contains(buf.String(), "caller=") {
+		t.Fatalf("framework entry has caller: %q", buf.String())
+	}
+}
+
+// newAccessLogFor 写入log的访问日志中间件，模拟hopter内部产生的日志
+func newAccessLogFor(log *logrus.Logger) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.Next()
+		log.WithField("status", ctx.Writer.Status()).Info("access")
+	}
+}
+
+func TestStdFrame(t *testing.T) {
+	for function, want := range map[string]bool{
+		"runtime.goexit":                 true,
+		"net/http.(*conn).serve":         true,
+		"main.main":                      false,
+		"github.com/acme/app/api.Handle": false,
+		"":                               true,
+	} {
+		if got := stdFrame(function); got != want {
+			t.Errorf("stdFrame(%q) = %v, want %v", function, got, want)
+		}
+	}
+}
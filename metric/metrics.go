@@ -92,3 +92,18 @@ func (m *Metric) Observe(labelValues []string, value float64) error {
 	}
 	return nil
 }
+
+// ObserveWithExemplar is used by Histogram type metric to add observations
+// with an exemplar. Other types and nil exemplars fall back to Observe.
+func (m *Metric) ObserveWithExemplar(labelValues []string, value float64, exemplar prometheus.Labels) error {
+	if m.Type != Histogram || exemplar == nil {
+		return m.Observe(labelValues, value)
+	}
+	observer := m.vec.(*prometheus.HistogramVec).WithLabelValues(labelValues...)
+	if eo, ok := observer.(prometheus.ExemplarObserver); ok {
+		eo.ObserveWithExemplar(value, exemplar)
+		return nil
+	}
+	observer.Observe(value)
+	return nil
+}
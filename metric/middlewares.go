@@ -3,10 +3,12 @@ package metric
 import (
 	"fmt"
 	"strconv"
+	"sync/atomic"
 	"time"
 
 	"github.com/allposs/hopter/metric/bloom"
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
@@ -20,6 +22,11 @@ var (
 	metricSlowRequest     = "gin_slow_request_total"
 
 	bloomFilter *bloom.BloomFilter
+
+	// metricHandler exposes metrics, OpenMetrics format is negotiated so that
+	// exemplars are visible to scrapers that support them.
+	metricHandler = promhttp.InstrumentMetricHandler(prometheus.DefaultRegisterer,
+		promhttp.HandlerFor(prometheus.DefaultGatherer, promhttp.HandlerOpts{EnableOpenMetrics: true}))
 )
 
 // Use set gin metrics middleware
@@ -28,7 +35,7 @@ func (m *Monitor) Use(r gin.IRoutes) {
 
 	r.Use(m.monitorInterceptor)
 	r.GET(m.metricPath, func(ctx *gin.Context) {
-		promhttp.Handler().ServeHTTP(ctx.Writer, ctx.Request)
+		metricHandler.ServeHTTP(ctx.Writer, ctx.Request)
 	})
 }
 
@@ -45,7 +52,7 @@ func (m *Monitor) UseWithoutExposingEndpoint(r gin.IRoutes) {
 // This allows to expose metrics on different port.
 func (m *Monitor) Expose(r gin.IRoutes) {
 	r.GET(m.metricPath, func(ctx *gin.Context) {
-		promhttp.Handler().ServeHTTP(ctx.Writer, ctx.Request)
+		metricHandler.ServeHTTP(ctx.Writer, ctx.Request)
 	})
 }
 
@@ -93,7 +100,7 @@ func (m *Monitor) initGinMetrics() {
 	_ = monitor.AddMetric(&Metric{
 		Type:        Counter,
 		Name:        metricSlowRequest,
-		Description: fmt.Sprintf("the server handled slow requests counter, t=%d.", m.slowTime),
+		Description: fmt.Sprintf("the server handled slow requests counter, t=%d.", atomic.LoadInt32(&m.slowTime)),
 		Labels:      []string{"uri", "method", "code"},
 	})
 }
@@ -137,12 +144,16 @@ func (m *Monitor) ginMetricHandle(ctx *gin.Context, start time.Time) {
 
 	// set slow request
 	latency := time.Since(start)
-	if int32(latency.Seconds()) > m.slowTime {
+	if int32(latency.Seconds()) > atomic.LoadInt32(&m.slowTime) {
 		_ = m.GetMetric(metricSlowRequest).Inc([]string{ctx.FullPath(), r.Method, strconv.Itoa(w.Status())})
 	}
 
 	// set request duration
-	_ = m.GetMetric(metricRequestDuration).Observe([]string{ctx.FullPath()}, latency.Seconds())
+	var exemplar prometheus.Labels
+	if m.exemplar != nil {
+		exemplar = m.exemplar(ctx)
+	}
+	_ = m.GetMetric(metricRequestDuration).ObserveWithExemplar([]string{ctx.FullPath()}, latency.Seconds(), exemplar)
 
 	// set response size
 	if w.Size() > 0 {
@@ -1,6 +1,9 @@
 package metric
 
 import (
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
 )
@@ -36,6 +39,7 @@ type Monitor struct {
 	metricPath  string
 	reqDuration []float64
 	metrics     map[string]*Metric
+	exemplar    func(ctx *gin.Context) prometheus.Labels
 }
 
 // GetMonitor used to get global Monitor object,
@@ -68,8 +72,15 @@ func (m *Monitor) SetMetricPath(path string) {
 
 // SetSlowTime set slowTime property. slowTime is used to determine whether
 // the request is slow. For "gin_slow_request_total" metric.
+// It is safe to call while serving requests.
 func (m *Monitor) SetSlowTime(slowTime int32) {
-	m.slowTime = slowTime
+	atomic.StoreInt32(&m.slowTime, slowTime)
+}
+
+// SetExemplar set the function used to attach exemplars (e.g. trace IDs)
+// to "gin_request_duration" observations. Returning nil skips the exemplar.
+func (m *Monitor) SetExemplar(fn func(ctx *gin.Context) prometheus.Labels) {
+	m.exemplar = fn
 }
 
 // SetDuration set reqDuration property. reqDuration is used to ginRequestDuration
@@ -1,49 +1,77 @@
 package hopter
 
 import (
-	"runtime/debug"
-
 	"github.com/allposs/hopter/metric"
 	"github.com/gin-gonic/gin"
 )
 
 // Middleware 中间件接口
 type Middleware interface {
-	// Handler 处理方法
+	// Handler 处理方法，会被并发调用
 	Handler(ctx *Context) error
-	//OnInject 用于对象注入
+	//OnInject 用于对象注入，返回的对象在启动时注入一次
 	OnInject() any
 }
 
+// metricConfig Metric配置
+type metricConfig struct {
+	// 指标路径
+	Path string `mapstructure:"path" default:"/metrics" validate:"startswith=/"`
+	// 慢请求阈值，单位秒
+	SlowTime int32 `mapstructure:"slowTime" default:"10" validate:"min=0"`
+	// 请求耗时分布，用于p95、p99
+	Duration []float64 `mapstructure:"duration" default:"0.1,0.3,1.2,5,10"`
+}
+
 // metric Metric插件
-func (e *Engine) metric() {
+func (e *Engine) metric() error {
+	conf := e.Endpoint.Config()
+	value, err := Bind[metricConfig](conf, "metric")
+	if err != nil {
+		return err
+	}
 	// get global Monitor object
 	m := metric.GetMonitor()
-	// +optional set metric path, default /debug/metrics
-	m.SetMetricPath("/metrics")
-	// +optional set slow time, default 5s
-	m.SetSlowTime(10)
-	// +optional set request duration, default {0.1, 0.3, 1.2, 5, 10}
-	// used to p95, p99
-	m.SetDuration([]float64{0.1, 0.3, 1.2, 5, 10})
-	// set middleware for gin
-	m.Use(e.engine)
+	m.SetMetricPath(value.Path)
+	m.SetSlowTime(value.SlowTime)
+	m.SetDuration(value.Duration)
+	m.SetExemplar(traceExemplar)
+	conf.OnChange("metric.slowTime", func(_, v any) {
+		if value, err := Bind[metricConfig](conf, "metric"); err == nil {
+			m.SetSlowTime(value.SlowTime)
+			Info("metric.slowTime已更新为%d", value.SlowTime)
+		}
+	})
+	// set middleware for gin, expose metrics on admin port if configured
+	if e.admin != nil {
+		m.UseWithoutExposingEndpoint(e.engine)
+		m.Expose(e.admin)
+	} else {
+		m.Use(e.engine)
+	}
 	e.beanFactory.set(m)
+	return nil
 }
 
-// Attach 中间件加入
+// Attach 中间件加入，作用于全部路由
 func (e *Engine) Attach(m ...Middleware) *Engine {
-	for _, v := range m {
-		e.engine.Use(func(ctx *gin.Context) {
-			e.beanFactory.Inject(v.OnInject())
-			err := v.Handler(&Context{ctx})
-			if err != nil {
-				ctx.AbortWithStatusJSON(400, gin.H{"web服务异常:%v,请联系管理人员": err})
-				debug.PrintStack()
-			} else {
-				ctx.Next()
-			}
-		})
-	}
+	e.engine.Use(e.middlewares(m)...)
 	return e
 }
+
+// middleware 转换为gin中间件
+// OnInject返回的对象是单例，只在注册时和启动前注入，请求相关的依赖使用Scoped和Resolve
+func (e *Engine) middleware(m Middleware) gin.HandlerFunc {
+	if v := m.OnInject(); v != nil {
+		_ = e.beanFactory.Inject(v)
+		e.injects = append(e.injects, v)
+	}
+	return func(ctx *gin.Context) {
+		c := &Context{ctx}
+		if err := m.Handler(c); err != nil {
+			abortWithError(c, err)
+		} else {
+			ctx.Next()
+		}
+	}
+}
@@ -0,0 +1,512 @@
+package hopter
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// openAPIVersion 生成的文档版本
+const openAPIVersion = "3.1.0"
+
+// schemaName 组件名中不允许的字符
+var schemaName = regexp.MustCompile(`[^A-Za-z0-9_.-]+`)
+
+// operationID 接口ID中不允许的字符
+var operationID = regexp.MustCompile(`[^A-Za-z0-9]+`)
+
+// pathParam gin路径参数，转换为OpenAPI的{name}
+var pathParam = regexp.MustCompile(`[:*]([^/]+)`)
+
+// openAPIConfig 文档配置
+type openAPIConfig struct {
+	Title       string `mapstructure:"title" default:"hopter"`
+	Version     string `mapstructure:"version" default:"1.0.0"`
+	Description string `mapstructure:"description"`
+}
+
+// OpenAPI OpenAPI文档
+type OpenAPI struct {
+	OpenAPI    string                           `json:"openapi"`
+	Info       OpenAPIInfo                      `json:"info"`
+	Paths      map[string]map[string]*Operation `json:"paths"`
+	Components Components                       `json:"components"`
+}
+
+// OpenAPIInfo 文档信息
+type OpenAPIInfo struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// Components 公共组件
+type Components struct {
+	Schemas         map[string]*Schema         `json:"schemas,omitempty"`
+	SecuritySchemes map[string]*SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+// SecurityScheme 认证方式
+type SecurityScheme struct {
+	// http|apiKey|oauth2|openIdConnect
+	Type         string `json:"type"`
+	Description  string `json:"description,omitempty"`
+	Scheme       string `json:"scheme,omitempty"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+	Name         string `json:"name,omitempty"`
+	// query|header|cookie
+	In               string `json:"in,omitempty"`
+	OpenIDConnectURL string `json:"openIdConnectUrl,omitempty"`
+}
+
+// Operation 接口
+type Operation struct {
+	Summary     string                `json:"summary,omitempty"`
+	Description string                `json:"description,omitempty"`
+	OperationID string                `json:"operationId,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	Parameters  []*Parameter          `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]*Response  `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+	Deprecated  bool                  `json:"deprecated,omitempty"`
+}
+
+// Parameter 接口参数
+type Parameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required,omitempty"`
+	Schema   *Schema `json:"schema"`
+}
+
+// RequestBody 请求体
+type RequestBody struct {
+	Required bool                  `json:"required,omitempty"`
+	Content  map[string]*MediaType `json:"content"`
+}
+
+// Response 响应
+type Response struct {
+	Description string                `json:"description"`
+	Content     map[string]*MediaType `json:"content,omitempty"`
+}
+
+// MediaType 媒体类型
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Schema JSON Schema
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Enum                 []any              `json:"enum,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+}
+
+// routeSpec 路由选项
+type routeSpec struct {
+	op          *Operation
+	middlewares []Middleware
+	typed       *typedOperation
+}
+
+// RouteOption 路由选项，用于补充接口文档和添加路由中间件
+type RouteOption func(spec *routeSpec)
+
+// Summary 接口摘要
+func Summary(summary string) RouteOption {
+	return func(spec *routeSpec) {
+		spec.op.Summary = summary
+	}
+}
+
+// Description 接口描述
+func Description(description string) RouteOption {
+	return func(spec *routeSpec) {
+		spec.op.Description = description
+	}
+}
+
+// Tags 接口分组
+func Tags(tags ...string) RouteOption {
+	return func(spec *routeSpec) {
+		spec.op.Tags = append(spec.op.Tags, tags...)
+	}
+}
+
+// Security 接口使用的认证方式，name为SecurityScheme注册的名称
+func Security(name string, scopes ...string) RouteOption {
+	return func(spec *routeSpec) {
+		spec.op.Security = append(spec.op.Security, map[string][]string{name: append([]string{}, scopes...)})
+	}
+}
+
+// Deprecated 接口已废弃
+func Deprecated() RouteOption {
+	return func(spec *routeSpec) {
+		spec.op.Deprecated = true
+	}
+}
+
+// With 只作用于该路由的中间件
+func With(m ...Middleware) RouteOption {
+	return func(spec *routeSpec) {
+		spec.middlewares = append(spec.middlewares, m...)
+	}
+}
+
+// route 已注册的路由
+type route struct {
+	method  string
+	path    string
+	typed   *typedOperation
+	options []RouteOption
+}
+
+// SecurityScheme 注册认证方式
+func (e *Engine) SecurityScheme(name string, scheme SecurityScheme) *Engine {
+	if e.securitySchemes == nil {
+		e.securitySchemes = make(map[string]*SecurityScheme)
+	}
+	e.securitySchemes[name] = &scheme
+	return e
+}
+
+// joinPath 拼接路由组和相对路径
+func joinPath(base, relative string) string {
+	if relative == "" {
+		return base
+	}
+	res := path.Join(base, relative)
+	if strings.HasSuffix(relative, "/") && !strings.HasSuffix(res, "/") {
+		return res + "/"
+	}
+	return res
+}
+
+// OpenAPI 根据已注册的路由生成OpenAPI文档
+func (e *Engine) OpenAPI() (*OpenAPI, error) {
+	conf, err := Bind[openAPIConfig](e.Endpoint.Config(), "openapi")
+	if err != nil {
+		return nil, err
+	}
+	g := &schemaGenerator{schemas: make(map[string]*Schema), names: make(map[reflect.Type]string)}
+	doc := &OpenAPI{
+		OpenAPI: openAPIVersion,
+		Info:    OpenAPIInfo{Title: conf.Title, Version: conf.Version, Description: conf.Description},
+		Paths:   make(map[string]map[string]*Operation),
+		Components: Components{
+			Schemas:         g.schemas,
+			SecuritySchemes: e.securitySchemes,
+		},
+	}
+	errorSchema := g.schema(reflect.TypeOf(ErrorBody{}))
+	for _, v := range e.routes {
+		p := pathParam.ReplaceAllString(v.path, "{$1}")
+		op := &Operation{
+			OperationID: strings.Trim(operationID.ReplaceAllString(strings.ToLower(v.method)+"_"+v.path, "_"), "_"),
+			Responses: map[string]*Response{
+				"default": {Description: "错误", Content: jsonContent(errorSchema)},
+			},
+		}
+		if v.typed != nil {
+			g.operation(op, v.method, v.typed)
+		} else {
+			op.Responses[strconv.Itoa(http.StatusOK)] = &Response{Description: "成功"}
+		}
+		spec := &routeSpec{op: op}
+		for _, option := range v.options {
+			option(spec)
+		}
+		if doc.Paths[p] == nil {
+			doc.Paths[p] = make(map[string]*Operation)
+		}
+		doc.Paths[p][strings.ToLower(v.method)] = op
+	}
+	return doc, nil
+}
+
+// ExportOpenAPI 把OpenAPI文档以JSON格式写入w
+func (e *Engine) ExportOpenAPI(w io.Writer) error {
+	doc, err := e.OpenAPI()
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(doc)
+}
+
+// jsonContent JSON媒体类型
+func jsonContent(schema *Schema) map[string]*MediaType {
+	return map[string]*MediaType{"application/json": {Schema: schema}}
+}
+
+// schemaGenerator 根据Go类型生成Schema，结构体放入components
+type schemaGenerator struct {
+	schemas map[string]*Schema
+	names   map[reflect.Type]string
+}
+
+// parameterLocations 参数位置和对应的标签，字段有多个标签时按顺序取第一个
+var parameterLocations = []struct {
+	in  string
+	tag string
+}{
+	{"path", "uri"},
+	{"query", "form"},
+	{"header", "header"},
+}
+
+// operation 根据强类型处理函数的请求和响应类型生成参数、请求体和响应
+func (g *schemaGenerator) operation(op *Operation, method string, typed *typedOperation) {
+	req := typed.req
+	for req.Kind() == reflect.Pointer {
+		req = req.Elem()
+	}
+	if req.Kind() == reflect.Struct {
+		body := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+		g.fields(req, func(field reflect.StructField) {
+			schema := g.schema(field.Type)
+			rules := parseRules(field.Tag.Get("binding"))
+			applyRules(schema, rules)
+			for _, v := range parameterLocations {
+				if name := tagName(field, v.tag); name != "" {
+					_, required := rules["required"]
+					op.Parameters = append(op.Parameters, &Parameter{Name: name, In: v.in, Required: required || v.in == "path", Schema: schema})
+					return
+				}
+			}
+			name := tagName(field, "json")
+			if name == "" {
+				name = field.Name
+			}
+			body.Properties[name] = schema
+			if _, ok := rules["required"]; ok {
+				body.Required = append(body.Required, name)
+			}
+		})
+		sort.Slice(op.Parameters, func(i, j int) bool {
+			if op.Parameters[i].In != op.Parameters[j].In {
+				return op.Parameters[i].In < op.Parameters[j].In
+			}
+			return op.Parameters[i].Name < op.Parameters[j].Name
+		})
+		switch method {
+		case http.MethodGet, http.MethodHead, http.MethodDelete:
+		default:
+			if len(body.Properties) > 0 {
+				op.RequestBody = &RequestBody{Required: true, Content: jsonContent(body)}
+			}
+		}
+	}
+	op.Responses[strconv.Itoa(http.StatusOK)] = &Response{Description: "成功", Content: jsonContent(g.schema(typed.resp))}
+}
+
+// fields 遍历结构体导出字段，展开匿名嵌入的结构体
+func (g *schemaGenerator) fields(t reflect.Type, fn func(field reflect.StructField)) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Anonymous {
+			ft := field.Type
+			for ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct && tagName(field, "json") == "" {
+				g.fields(ft, fn)
+				continue
+			}
+		}
+		if !field.IsExported() || field.Tag.Get("json") == "-" {
+			continue
+		}
+		fn(field)
+	}
+}
+
+// schema 生成类型对应的Schema
+func (g *schemaGenerator) schema(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t {
+	case reflect.TypeOf(time.Time{}):
+		return &Schema{Type: "string", Format: "date-time"}
+	case reflect.TypeOf(time.Duration(0)):
+		return &Schema{Type: "integer", Format: "int64"}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: g.schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.schema(t.Elem())}
+	case reflect.Struct:
+		return g.ref(t)
+	default:
+		return &Schema{}
+	}
+}
+
+// ref 结构体放入components并返回引用
+func (g *schemaGenerator) ref(t reflect.Type) *Schema {
+	if t.Name() == "" {
+		return g.object(t)
+	}
+	name, ok := g.names[t]
+	if !ok {
+		name = schemaName.ReplaceAllString(t.Name(), "_")
+		if _, exists := g.schemas[name]; exists {
+			name = schemaName.ReplaceAllString(path.Base(t.PkgPath())+"."+t.Name(), "_")
+		}
+		g.names[t] = name
+		// 先占位，避免递归类型死循环
+		g.schemas[name] = &Schema{}
+		*g.schemas[name] = *g.object(t)
+	}
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+// object 生成结构体的Schema
+func (g *schemaGenerator) object(t reflect.Type) *Schema {
+	res := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	g.fields(t, func(field reflect.StructField) {
+		name := tagName(field, "json")
+		if name == "" {
+			name = field.Name
+		}
+		schema := g.schema(field.Type)
+		rules := parseRules(field.Tag.Get("binding"))
+		applyRules(schema, rules)
+		res.Properties[name] = schema
+		if _, ok := rules["required"]; ok {
+			res.Required = append(res.Required, name)
+		}
+	})
+	return res
+}
+
+// tagName 标签中的名称
+func tagName(field reflect.StructField, tag string) string {
+	name, _, _ := strings.Cut(field.Tag.Get(tag), ",")
+	if name == "-" {
+		return ""
+	}
+	return name
+}
+
+// parseRules 解析binding标签
+func parseRules(tag string) map[string]string {
+	res := make(map[string]string)
+	for _, rule := range strings.Split(tag, ",") {
+		if rule == "" {
+			continue
+		}
+		k, v, _ := strings.Cut(rule, "=")
+		res[k] = v
+	}
+	return res
+}
+
+// applyRules 把常用的校验规则转换为Schema约束，引用类型不处理
+func applyRules(schema *Schema, rules map[string]string) {
+	if schema.Ref != "" {
+		return
+	}
+	if v, ok := rules["oneof"]; ok {
+		for _, item := range strings.Fields(v) {
+			schema.Enum = append(schema.Enum, item)
+		}
+	}
+	if _, ok := rules["email"]; ok {
+		schema.Format = "email"
+	}
+	for _, k := range []string{"min", "max", "gte", "lte"} {
+		v, ok := rules[k]
+		if !ok {
+			continue
+		}
+		n, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			continue
+		}
+		isMin := k == "min" || k == "gte"
+		switch schema.Type {
+		case "string":
+			length := int(n)
+			if isMin {
+				schema.MinLength = &length
+			} else {
+				schema.MaxLength = &length
+			}
+		case "integer", "number":
+			if isMin {
+				schema.Minimum = &n
+			} else {
+				schema.Maximum = &n
+			}
+		}
+	}
+}
+
+// openAPIHandler 输出OpenAPI文档
+func (e *Engine) openAPIHandler(ctx *gin.Context) {
+	doc, err := e.OpenAPI()
+	if err != nil {
+		abortWithError(&Context{ctx}, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, doc)
+}
+
+// Command 处理命令行子命令，openapi [file] 导出OpenAPI文档，返回是否处理了子命令
+// 需要由应用在Run之前显式调用，如 if ok, err := e.Command(os.Args[1:]); ok { ... }
+func (e *Engine) Command(args []string) (bool, error) {
+	if len(args) == 0 || args[0] != "openapi" {
+		return false, nil
+	}
+	if len(args) == 1 {
+		return true, e.ExportOpenAPI(os.Stdout)
+	}
+	f, err := os.Create(args[1])
+	if err != nil {
+		return true, fmt.Errorf("导出OpenAPI文档失败:%v", err)
+	}
+	defer f.Close()
+	return true, e.ExportOpenAPI(f)
+}
@@ -0,0 +1,48 @@
+package hopter
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type locationRequest struct {
+	// 同时有uri和form标签时作为路径参数
+	ID string `uri:"id" form:"id"`
+}
+
+func TestParameterLocationOrder(t *testing.T) {
+	e := newTestEngine()
+	e.Handle("GET", "/items/:id", func(*Context) Message { return nil }, Types[locationRequest, string]())
+	for i := 0; i < 20; i++ {
+		doc, err := e.OpenAPI()
+		if err != nil {
+			t.Fatalf("OpenAPI: %v", err)
+		}
+		params := doc.Paths["/items/{id}"]["get"].Parameters
+		if len(params) != 1 || params[0].In != "path" {
+			t.Fatalf("parameters = %+v, want one path parameter", params)
+		}
+	}
+}
+
+func TestCommand(t *testing.T) {
+	e := newTestEngine()
+	if ok, _ := e.Command([]string{"serve"}); ok {
+		t.Fatal("Command handled unknown sub command")
+	}
+	file := filepath.Join(t.TempDir(), "openapi.json")
+	ok, err := e.Command([]string{"openapi", file})
+	if !ok || err != nil {
+		t.Fatalf("Command = %v, %v", ok, err)
+	}
+	b, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc OpenAPI
+	if err := json.Unmarshal(b, &doc); err != nil || doc.OpenAPI != openAPIVersion {
+		t.Fatalf("exported document = %s, %v", b, err)
+	}
+}
@@ -0,0 +1,174 @@
+package hopter
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// EnvProfile 指定配置环境的环境变量，优先于server.env
+const EnvProfile = "HOPTER_ENV"
+
+// 配置来源
+const (
+	SourceOverride = "override"
+	SourceFlag     = "flag"
+	SourceEnv      = "env"
+	SourceDefault  = "default"
+)
+
+// configLayer 配置文件层
+type configLayer struct {
+	// 文件路径
+	file string
+	// 文件内容
+	content []byte
+	// 文件中的配置，密钥引用已解析
+	settings map[string]any
+	// 引用了密钥的key
+	secrets []string
+}
+
+// secretError 密钥解析异常
+type secretError struct {
+	file string
+	err  error
+}
+
+func (e *secretError) Error() string {
+	return fmt.Sprintf("解析配置文件%s中的密钥异常,%v", e.file, e.err)
+}
+
+func (e *secretError) Unwrap() error {
+	return e.err
+}
+
+// profileFile 环境配置文件路径，如config/config.yaml对应config/config.prod.yaml
+func profileFile(base, profile string) string {
+	ext := filepath.Ext(base)
+	return strings.TrimSuffix(base, ext) + "." + profile + ext
+}
+
+// readLayer 读取配置文件层
+func readLayer(file string) (configLayer, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return configLayer{}, err
+	}
+	v := viper.New()
+	v.SetConfigType(strings.TrimPrefix(filepath.Ext(file), "."))
+	if err := v.ReadConfig(bytes.NewReader(content)); err != nil {
+		return configLayer{}, fmt.Errorf("解析配置文件%s异常,%v", file, err)
+	}
+	settings := v.AllSettings()
+	secrets, err := resolveSecrets(settings, "")
+	if err != nil {
+		return configLayer{}, &secretError{file, err}
+	}
+	return configLayer{file: file, content: content, settings: settings, secrets: secrets}, nil
+}
+
+// readLayers 按顺序读取配置文件层
+func readLayers(files []string) ([]configLayer, error) {
+	res := make([]configLayer, 0, len(files))
+	for _, file := range files {
+		layer, err := readLayer(file)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, layer)
+	}
+	return res, nil
+}
+
+// applyLayers 用配置文件层替换viper中的配置文件内容，后面的层覆盖前面的层，调用时需持有锁
+func (c *config) applyLayers(layers []configLayer) error {
+	secrets := make(map[string]bool)
+	for i, layer := range layers {
+		// 第一层替换原有的配置文件内容
+		if i == 0 {
+			if err := c.Viper.ReadConfig(bytes.NewReader(layer.content)); err != nil {
+				return err
+			}
+		}
+		if err := c.Viper.MergeConfigMap(layer.settings); err != nil {
+			return err
+		}
+		for _, key := range layer.secrets {
+			secrets[key] = true
+		}
+	}
+	c.layers = layers
+	c.secrets = secrets
+	return nil
+}
+
+// profile 当前配置环境，调用时需持有锁
+func (c *config) profile() string {
+	if v := os.Getenv(EnvProfile); v != "" {
+		return v
+	}
+	return c.Viper.GetString("server.env")
+}
+
+// Profile 当前配置环境
+func (c *config) Profile() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.profile()
+}
+
+// BindFlags 绑定命令行参数，参数名即配置的key，如--server.port
+func (c *config) BindFlags(flags *pflag.FlagSet) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.Viper.BindPFlags(flags); err != nil {
+		return err
+	}
+	c.flags = flags
+	return nil
+}
+
+// envName key对应的环境变量名
+func (c *config) envName(key string) string {
+	return strings.ToUpper(c.prefix + "_" + envKeyReplacer.Replace(key))
+}
+
+// Source 获取key的值来自哪一层配置
+// 返回override|flag|env|配置文件路径|default，未配置时返回空字符串
+func (c *config) Source(key string) string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	key = strings.ToLower(key)
+	if c.overrides[key] {
+		return SourceOverride
+	}
+	if c.flags != nil {
+		if f := c.flags.Lookup(key); f != nil && f.Changed {
+			return SourceFlag
+		}
+	}
+	if c.prefix != "" {
+		if _, ok := os.LookupEnv(c.envName(key)); ok {
+			return SourceEnv
+		}
+	}
+	for i := len(c.layers) - 1; i >= 0; i-- {
+		if _, ok := lookupSetting(c.layers[i].settings, key); ok {
+			return c.layers[i].file
+		}
+	}
+	if c.Viper.IsSet(key) {
+		return SourceDefault
+	}
+	// 命令行参数的默认值
+	if c.flags != nil && c.flags.Lookup(key) != nil {
+		return SourceDefault
+	}
+	return ""
+}
@@ -0,0 +1,262 @@
+package hopter
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin/binding"
+	ginrender "github.com/gin-gonic/gin/render"
+	"google.golang.org/protobuf/proto"
+)
+
+// ErrUnsupportedData 渲染器不支持该数据类型，继续尝试下一个渲染器
+var ErrUnsupportedData = errors.New("渲染器不支持该数据类型")
+
+// ErrNotAcceptable 没有与Accept匹配的渲染器
+var ErrNotAcceptable = NewError(http.StatusNotAcceptable, "NOT_ACCEPTABLE", "不支持请求的响应格式")
+
+var (
+	rendererMu sync.RWMutex
+	// renderers 按注册顺序排列，Accept为空或*/*时使用第一个
+	renderers = []Renderer{
+		jsonRenderer{},
+		xmlRenderer{},
+		yamlRenderer{},
+		msgPackRenderer{},
+		protoBufRenderer{},
+	}
+)
+
+// Renderer 响应渲染器
+type Renderer interface {
+	// ContentTypes 支持的媒体类型，第一个为默认
+	ContentTypes() []string
+	// Render 写入响应，不支持data的类型或编码失败时在写入前返回ErrUnsupportedData
+	Render(ctx *Context, status int, data any) error
+}
+
+// RegisterRenderer 注册渲染器，媒体类型与已有渲染器相同时在原位置替换，否则添加到最后
+func RegisterRenderer(r Renderer) {
+	rendererMu.Lock()
+	defer rendererMu.Unlock()
+	types := make(map[string]bool)
+	for _, v := range r.ContentTypes() {
+		types[v] = true
+	}
+	res := make([]Renderer, 0, len(renderers)+1)
+	replaced := false
+	for _, v := range renderers {
+		if !types[v.ContentTypes()[0]] {
+			res = append(res, v)
+			continue
+		}
+		// 同时匹配多个已有渲染器时替换第一个，其余移除
+		if !replaced {
+			res = append(res, r)
+			replaced = true
+		}
+	}
+	if !replaced {
+		res = append(res, r)
+	}
+	renderers = res
+}
+
+// mediaRange Accept中的媒体类型
+type mediaRange struct {
+	value string
+	q     float64
+}
+
+// parseAccept 解析Accept并按q值从高到低排序
+func parseAccept(accept string) []mediaRange {
+	var res []mediaRange
+	for _, part := range strings.Split(accept, ",") {
+		value, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		if q > 0 {
+			res = append(res, mediaRange{value, q})
+		}
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		return res[i].q > res[j].q
+	})
+	return res
+}
+
+// match 媒体类型是否匹配，支持type/*和*/*
+func (m mediaRange) match(contentType string) bool {
+	if m.value == "*/*" || m.value == contentType {
+		return true
+	}
+	prefix, ok := strings.CutSuffix(m.value, "/*")
+	return ok && strings.HasPrefix(contentType, prefix+"/")
+}
+
+// negotiate 按Accept返回候选渲染器
+func negotiate(accept string) []Renderer {
+	rendererMu.RLock()
+	defer rendererMu.RUnlock()
+	if strings.TrimSpace(accept) == "" {
+		return renderers[:1]
+	}
+	var res []Renderer
+	// 按下标记录，渲染器可能是不能作为map键的类型
+	seen := make([]bool, len(renderers))
+	for _, m := range parseAccept(accept) {
+		for i, r := range renderers {
+			if seen[i] {
+				continue
+			}
+			for _, contentType := range r.ContentTypes() {
+				if m.match(contentType) {
+					res = append(res, r)
+					seen[i] = true
+					break
+				}
+			}
+		}
+	}
+	return res
+}
+
+// render 按Accept写入消息，处理函数已自行写入响应时忽略
+func render(ctx *Context, msg Message) {
+	if ctx.Writer.Written() {
+		return
+	}
+	status := http.StatusOK
+	if v, ok := msg.(StatusMessage); ok {
+		status = v.StatusCode()
+	}
+	data := msg.Send(*ctx)
+	var unsupported error
+	for _, r := range negotiate(ctx.GetHeader("Accept")) {
+		err := r.Render(ctx, status, data)
+		if errors.Is(err, ErrUnsupportedData) {
+			unsupported = err
+			continue
+		}
+		if err != nil {
+			Error("web服务异常:响应写入失败,%v", err)
+		}
+		return
+	}
+	if unsupported != nil {
+		ctx.Logger().Warnf("响应编码失败:%v", unsupported)
+	}
+	// 错误响应不再返回406，避免掩盖原始错误
+	if _, ok := msg.(*HTTPError); ok {
+		if err := (jsonRenderer{}).Render(ctx, status, data); err == nil {
+			return
+		}
+		// 详情无法编码为JSON时去掉详情
+		if body, ok := data.(ErrorBody); ok {
+			body.Details = nil
+			data = body
+		}
+		ctx.JSON(status, data)
+		return
+	}
+	ctx.JSON(ErrNotAcceptable.Status, ErrNotAcceptable.Send(*ctx))
+}
+
+// jsonRenderer JSON渲染器
+type jsonRenderer struct{}
+
+func (jsonRenderer) ContentTypes() []string {
+	return []string{binding.MIMEJSON}
+}
+
+func (jsonRenderer) Render(ctx *Context, status int, data any) error {
+	return write(ctx, status, ginrender.JSON{Data: data})
+}
+
+// xmlRenderer XML渲染器
+type xmlRenderer struct{}
+
+func (xmlRenderer) ContentTypes() []string {
+	return []string{binding.MIMEXML, binding.MIMEXML2}
+}
+
+func (xmlRenderer) Render(ctx *Context, status int, data any) error {
+	return write(ctx, status, ginrender.XML{Data: data})
+}
+
+// yamlRenderer YAML渲染器
+type yamlRenderer struct{}
+
+func (yamlRenderer) ContentTypes() []string {
+	return []string{binding.MIMEYAML2, binding.MIMEYAML}
+}
+
+func (yamlRenderer) Render(ctx *Context, status int, data any) error {
+	return write(ctx, status, ginrender.YAML{Data: data})
+}
+
+// msgPackRenderer MessagePack渲染器
+type msgPackRenderer struct{}
+
+func (msgPackRenderer) ContentTypes() []string {
+	return []string{binding.MIMEMSGPACK2, binding.MIMEMSGPACK}
+}
+
+func (msgPackRenderer) Render(ctx *Context, status int, data any) error {
+	return write(ctx, status, ginrender.MsgPack{Data: data})
+}
+
+// protoBufRenderer protobuf渲染器，只支持proto.Message
+type protoBufRenderer struct{}
+
+func (protoBufRenderer) ContentTypes() []string {
+	return []string{binding.MIMEPROTOBUF}
+}
+
+func (protoBufRenderer) Render(ctx *Context, status int, data any) error {
+	if _, ok := data.(proto.Message); !ok {
+		return ErrUnsupportedData
+	}
+	return write(ctx, status, ginrender.ProtoBuf{Data: data})
+}
+
+// write 先编码到缓冲区，成功后再写入状态码和响应体
+// 编码失败时还未写入任何内容，返回ErrUnsupportedData以便尝试下一个渲染器
+func write(ctx *Context, status int, r ginrender.Render) error {
+	buf := &bufferWriter{header: make(http.Header)}
+	if err := r.Render(buf); err != nil {
+		return fmt.Errorf("%w,%v", ErrUnsupportedData, err)
+	}
+	for k, v := range buf.header {
+		ctx.Writer.Header()[k] = v
+	}
+	ctx.Status(status)
+	_, err := ctx.Writer.Write(buf.Bytes())
+	return err
+}
+
+// bufferWriter 缓存响应头和响应体的http.ResponseWriter
+type bufferWriter struct {
+	header http.Header
+	bytes.Buffer
+}
+
+func (w *bufferWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *bufferWriter) WriteHeader(int) {}
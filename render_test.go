@@ -0,0 +1,101 @@
+package hopter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin/binding"
+)
+
+// sliceRenderer 含切片字段，不能作为map的键
+type sliceRenderer struct {
+	types []string
+}
+
+func (r sliceRenderer) ContentTypes() []string {
+	return r.types
+}
+
+func (sliceRenderer) Render(*Context, int, any) error {
+	return nil
+}
+
+// restoreRenderers 测试结束后恢复已注册的渲染器
+func restoreRenderers(t *testing.T) {
+	old := append([]Renderer(nil), renderers...)
+	t.Cleanup(func() {
+		renderers = old
+	})
+}
+
+func TestRegisterRendererInPlace(t *testing.T) {
+	restoreRenderers(t)
+	custom := sliceRenderer{types: []string{binding.MIMEJSON}}
+	RegisterRenderer(custom)
+	res := negotiate("")
+	if len(res) != 1 {
+		t.Fatalf("negotiate = %d renderers, want 1", len(res))
+	}
+	if _, ok := res[0].(sliceRenderer); !ok {
+		t.Fatalf("default renderer = %T, want sliceRenderer", res[0])
+	}
+	if _, ok := renderers[1].(xmlRenderer); !ok {
+		t.Fatalf("renderers[1] = %T, want xmlRenderer", renderers[1])
+	}
+}
+
+func TestNegotiateUnhashableRenderer(t *testing.T) {
+	restoreRenderers(t)
+	RegisterRenderer(sliceRenderer{types: []string{"text/csv"}})
+	res := negotiate("text/csv, */*;q=0.1")
+	if len(res) != len(renderers) {
+		t.Fatalf("negotiate = %d renderers, want %d", len(res), len(renderers))
+	}
+	if _, ok := res[0].(sliceRenderer); !ok {
+		t.Fatalf("res[0] = %T, want sliceRenderer", res[0])
+	}
+	if _, ok := res[1].(jsonRenderer); !ok {
+		t.Fatalf("res[1] = %T, want jsonRenderer", res[1])
+	}
+}
+
+type renderItem struct {
+	Name string `json:"name" xml:"name"`
+}
+
+func TestRenderEncodeFailure(t *testing.T) {
+	e := newTestEngine()
+	e.Handle("GET", "/map", func(*Context) Message {
+		return payload{map[string]string{"name": "a"}}
+	})
+	e.Handle("GET", "/item", func(*Context) Message {
+		return payload{renderItem{Name: "a"}}
+	})
+	e.Handle("GET", "/error", func(ctx *Context) Message {
+		return ctx.Fail(ErrNotFound.WithDetails(map[string]string{"id": "1"}))
+	})
+	tests := []struct {
+		path        string
+		accept      string
+		status      int
+		contentType string
+		body        string
+	}{
+		// map不能编码为XML，不能返回200和空响应体
+		{"/map", "application/xml", http.StatusNotAcceptable, binding.MIMEJSON, "NOT_ACCEPTABLE"},
+		{"/map", "application/xml, application/json;q=0.5", http.StatusOK, binding.MIMEJSON, `"name":"a"`},
+		{"/item", "application/xml", http.StatusOK, binding.MIMEXML, "<name>a</name>"},
+		{"/error", "application/xml", http.StatusNotFound, binding.MIMEJSON, `"id":"1"`},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", tt.path, nil)
+		req.Header.Set("Accept", tt.accept)
+		w := httptest.NewRecorder()
+		e.engine.ServeHTTP(w, req)
+		if w.Code != tt.status || !strings.HasPrefix(w.Header().Get("Content-Type"), tt.contentType) || !strings.Contains(w.Body.String(), tt.body) {
+			t.Errorf("GET %s Accept %q = %d %s %q", tt.path, tt.accept, w.Code, w.Header().Get("Content-Type"), w.Body.String())
+		}
+	}
+}
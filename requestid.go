@@ -0,0 +1,82 @@
+package hopter
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// RequestIDHeader 请求ID的请求头和响应头
+	RequestIDHeader = "X-Request-ID"
+	// requestIDKey 请求ID在gin.Context中的键
+	requestIDKey = "hopter.requestId"
+	// maxRequestIDLength 接受的请求ID最大长度
+	maxRequestIDLength = 128
+)
+
+// requestIDMiddleware 接受或生成请求ID，保存到Context并写入响应头
+func requestIDMiddleware() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		id := ctx.GetHeader(RequestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		ctx.Set(requestIDKey, id)
+		ctx.Header(RequestIDHeader, id)
+		ctx.Next()
+	}
+}
+
+// validRequestID 只接受长度合适的可见ASCII字符，避免日志注入
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// newRequestID 生成32位十六进制的请求ID
+func newRequestID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// RequestID 得到当前请求的ID
+func (ctx *Context) RequestID() string {
+	return requestID(ctx.Context)
+}
+
+// requestID 得到请求ID，未经过请求ID中间件时使用请求头
+func requestID(c *gin.Context) string {
+	if id := c.GetString(requestIDKey); id != "" {
+		return id
+	}
+	return c.GetHeader(RequestIDHeader)
+}
+
+// Logger 得到带有请求ID、路由和客户端IP的日志条目，同一请求的日志可以关联起来
+func (ctx *Context) Logger() *logrus.Entry {
+	return requestLogger(ctx.Context)
+}
+
+// requestLogger 请求相关的日志条目
+func requestLogger(c *gin.Context) *logrus.Entry {
+	log := logrus.StandardLogger()
+	if logs != nil {
+		log = logs.Logger
+	}
+	return log.WithContext(c.Request.Context()).WithFields(logrus.Fields{
+		"requestId": requestID(c),
+		"route":     c.FullPath(),
+		"clientIp":  c.ClientIP(),
+	})
+}
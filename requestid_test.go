@@ -0,0 +1,73 @@
+package hopter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+func TestRequestIDHeader(t *testing.T) {
+	r := gin.New()
+	r.Use(requestIDMiddleware())
+	var got string
+	r.GET("/", func(ctx *gin.Context) {
+		got = (&Context{ctx}).RequestID()
+	})
+	tests := []struct {
+		name string
+		id   string
+		keep bool
+	}{
+		{"echo", "abc-123", true},
+		{"missing", "", false},
+		{"control", "abc\ninjected", false},
+		{"too long", strings.Repeat("a", maxRequestIDLength+1), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.id != "" {
+				req.Header.Set(RequestIDHeader, tt.id)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			res := w.Header().Get(RequestIDHeader)
+			if res != got {
+				t.Fatalf("response id %q != context id %q", res, got)
+			}
+			if tt.keep && res != tt.id {
+				t.Fatalf("id = %q, want %q", res, tt.id)
+			}
+			// 不合法时替换为32位十六进制ID
+			if !tt.keep && (res == tt.id || len(res) != 32) {
+				t.Fatalf("id = %q, want a generated id", res)
+			}
+		})
+	}
+}
+
+func TestRequestLoggerFields(t *testing.T) {
+	r := gin.New()
+	r.Use(requestIDMiddleware())
+	var entry *logrus.Entry
+	r.GET("/users/:id", func(ctx *gin.Context) {
+		entry = (&Context{ctx}).Logger()
+	})
+	req := httptest.NewRequest(http.MethodGet, "/users/1", nil)
+	req.Header.Set(RequestIDHeader, "req-1")
+	req.RemoteAddr = "10.0.0.1:1234"
+	r.ServeHTTP(httptest.NewRecorder(), req)
+	want := logrus.Fields{"requestId": "req-1", "route": "/users/:id", "clientIp": "10.0.0.1"}
+	for k, v := range want {
+		if entry.Data[k] != v {
+			t.Fatalf("%s = %v, want %v", k, entry.Data[k], v)
+		}
+	}
+	if entry.Context != req.Context() {
+		t.Fatal("logger entry does not carry the request context")
+	}
+}
@@ -0,0 +1,75 @@
+package hopter
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+// Router 路由组，Mount和Group返回，路由和中间件只作用于该组
+// 只提供路由组范围的方法，Attach、Beans等全局方法需要通过Engine调用
+type Router struct {
+	engine *Engine
+	group  *gin.RouterGroup
+}
+
+// Handle 在路由组中注册路由
+func (r *Router) Handle(httpMethod, relativePath string, handler HandlerFunc, opts ...RouteOption) *Router {
+	r.engine.handle(r.group, httpMethod, relativePath, handler, nil, opts...)
+	return r
+}
+
+// HandleTyped 在路由组中注册Typed创建的处理函数
+func (r *Router) HandleTyped(httpMethod, relativePath string, handler *TypedHandler, opts ...RouteOption) *Router {
+	r.engine.handle(r.group, httpMethod, relativePath, handler.handler, handler.typed, opts...)
+	return r
+}
+
+// Group 创建子路由组，m只作用于子路由组
+func (r *Router) Group(relativePath string, m ...Middleware) *Router {
+	return r.engine.newRouter(r.group, relativePath, m...)
+}
+
+// Use 为路由组添加中间件，只对之后注册的路由生效
+func (r *Router) Use(m ...Middleware) *Router {
+	r.group.Use(r.engine.middlewares(m)...)
+	return r
+}
+
+// Mount 在路由组下挂载服务
+func (r *Router) Mount(group string, class ...Service) *Router {
+	return r.engine.mount(r.group, group, class...)
+}
+
+// BasePath 路由组路径
+func (r *Router) BasePath() string {
+	return r.group.BasePath()
+}
+
+// Group 创建路由组，m只作用于该路由组
+func (e *Engine) Group(relativePath string, m ...Middleware) *Router {
+	return e.newRouter(e.group, relativePath, m...)
+}
+
+// newRouter 在parent下创建路由组
+func (e *Engine) newRouter(parent *gin.RouterGroup, relativePath string, m ...Middleware) *Router {
+	return &Router{e, parent.Group(relativePath, e.middlewares(m)...)}
+}
+
+// middlewares 转换为gin中间件
+func (e *Engine) middlewares(m []Middleware) []gin.HandlerFunc {
+	res := make([]gin.HandlerFunc, 0, len(m))
+	for _, v := range m {
+		res = append(res, e.middleware(v))
+	}
+	return res
+}
+
+// handle 在group中注册路由，typed为处理函数的请求和响应类型，opts中的中间件只作用于该路由
+func (e *Engine) handle(group *gin.RouterGroup, httpMethod, relativePath string, handler HandlerFunc, typed *typedOperation, opts ...RouteOption) {
+	spec := &routeSpec{op: new(Operation), typed: typed}
+	for _, option := range opts {
+		option(spec)
+	}
+	handlers := append(e.middlewares(spec.middlewares), handler.Func())
+	group.Handle(httpMethod, relativePath, handlers...)
+	e.routes = append(e.routes, route{method: httpMethod, path: joinPath(group.BasePath(), relativePath), typed: spec.typed, options: opts})
+}
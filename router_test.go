@@ -0,0 +1,48 @@
+package hopter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// headerMiddleware 写入响应头的中间件
+type headerMiddleware string
+
+func (m headerMiddleware) Handler(ctx *Context) error {
+	ctx.Header("X-Middleware", string(m))
+	return nil
+}
+
+func (headerMiddleware) OnInject() any {
+	return nil
+}
+
+func TestRouterGroupMiddleware(t *testing.T) {
+	e := newTestEngine()
+	ok := func(ctx *Context) Message {
+		ctx.Status(http.StatusNoContent)
+		return nil
+	}
+	api := e.Group("/api", headerMiddleware("api"))
+	api.Handle("GET", "/users", ok)
+	api.Group("/admin").Use(headerMiddleware("admin")).Handle("GET", "/cache", ok)
+	e.Handle("GET", "/ping", ok)
+	if got := api.BasePath(); got != "/api" {
+		t.Fatalf("BasePath = %q, want /api", got)
+	}
+	for path, want := range map[string]string{
+		"/api/users":       "api",
+		"/api/admin/cache": "admin",
+		"/ping":            "",
+	} {
+		w := httptest.NewRecorder()
+		e.engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != http.StatusNoContent {
+			t.Fatalf("%s status = %d", path, w.Code)
+		}
+		if got := w.Header().Get("X-Middleware"); got != want {
+			t.Fatalf("%s middleware = %q, want %q", path, got, want)
+		}
+	}
+}
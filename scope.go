@@ -0,0 +1,173 @@
+package hopter
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// scopeKey 请求作用域在gin.Context中的key
+const scopeKey = "hopter/scope"
+
+// scopeProvider 请求作用域依赖的创建函数
+type scopeProvider func(ctx *Context) (value any, release func(), err error)
+
+// requestScope 请求作用域，保存本次请求创建的依赖
+type requestScope struct {
+	mu        sync.Mutex
+	providers map[reflect.Type]scopeProvider
+	values    map[reflect.Type]*scopeEntry
+	// resolving 传给provider的Context对应的创建链，用于检测循环依赖
+	resolving map[*Context][]reflect.Type
+	releases  []func()
+}
+
+// scopeEntry 请求作用域中的依赖，done关闭后value和err可用
+type scopeEntry struct {
+	done  chan struct{}
+	value any
+	err   error
+}
+
+// newScope 创建请求作用域
+func newScope(providers map[reflect.Type]scopeProvider) *requestScope {
+	return &requestScope{
+		providers: providers,
+		values:    make(map[reflect.Type]*scopeEntry),
+		resolving: make(map[*Context][]reflect.Type),
+	}
+}
+
+// Scoped 注册请求作用域的依赖，每个请求第一次Resolve时调用fn创建，
+// 请求结束时按创建的相反顺序调用release释放，release可以为nil
+func Scoped[T any](e *Engine, fn func(ctx *Context) (value T, release func(), err error)) *Engine {
+	e.scopes[reflect.TypeFor[T]()] = func(ctx *Context) (any, func(), error) {
+		return fn(ctx)
+	}
+	return e
+}
+
+// Provide 在当前请求中直接提供依赖，如认证中间件提供当前用户
+func Provide[T any](ctx *Context, value T, release func()) {
+	scope := getScope(ctx)
+	scope.mu.Lock()
+	defer scope.mu.Unlock()
+	done := make(chan struct{})
+	close(done)
+	scope.values[reflect.TypeFor[T]()] = &scopeEntry{done: done, value: value}
+	if release != nil {
+		scope.releases = append(scope.releases, release)
+	}
+}
+
+// Resolve 获取当前请求中的依赖，不存在时使用Scoped注册的函数创建
+// 调用provider时不持有锁，provider中可以继续Resolve其他依赖，同一类型并发Resolve时只创建一次
+func Resolve[T any](ctx *Context) (T, error) {
+	var res T
+	value, err := getScope(ctx).resolve(ctx, reflect.TypeFor[T]())
+	if err != nil {
+		return res, err
+	}
+	return value.(T), nil
+}
+
+// resolve 获取或创建t类型的依赖
+func (scope *requestScope) resolve(ctx *Context, t reflect.Type) (any, error) {
+	scope.mu.Lock()
+	chain := scope.resolving[ctx]
+	if slices.Contains(chain, t) {
+		scope.mu.Unlock()
+		return nil, scopeCycleError(append(slices.Clone(chain), t))
+	}
+	if entry, ok := scope.values[t]; ok {
+		scope.mu.Unlock()
+		<-entry.done
+		return entry.value, entry.err
+	}
+	provider, ok := scope.providers[t]
+	if !ok {
+		scope.mu.Unlock()
+		return nil, fmt.Errorf("请求作用域中没有%v类型的依赖", t)
+	}
+	entry := &scopeEntry{done: make(chan struct{})}
+	scope.values[t] = entry
+	// provider中的Resolve使用child，通过child得到创建链
+	child := &Context{ctx.Context}
+	scope.resolving[child] = append(slices.Clone(chain), t)
+	scope.mu.Unlock()
+	var (
+		release  func()
+		returned bool
+	)
+	defer func() {
+		if !returned {
+			// provider panic时等待中的Resolve返回错误，panic继续向上传递
+			entry.err = fmt.Errorf("创建%v类型的依赖时panic", t)
+		}
+		scope.mu.Lock()
+		delete(scope.resolving, child)
+		if entry.err != nil {
+			// 创建失败时移除，之后的Resolve重新创建
+			if scope.values[t] == entry {
+				delete(scope.values, t)
+			}
+		} else if release != nil {
+			scope.releases = append(scope.releases, release)
+		}
+		scope.mu.Unlock()
+		close(entry.done)
+	}()
+	entry.value, release, entry.err = provider(child)
+	returned = true
+	return entry.value, entry.err
+}
+
+// scopeCycleError 请求作用域依赖的循环错误
+func scopeCycleError(chain []reflect.Type) error {
+	names := make([]string, 0, len(chain))
+	for _, v := range chain {
+		names = append(names, v.String())
+	}
+	return fmt.Errorf("请求作用域依赖存在循环:%s", strings.Join(names, " -> "))
+}
+
+// MustResolve 获取当前请求中的依赖，失败时panic，由recovered返回500
+func MustResolve[T any](ctx *Context) T {
+	res, err := Resolve[T](ctx)
+	if err != nil {
+		panic(err)
+	}
+	return res
+}
+
+// getScope 获取请求作用域，未经过scopeMiddleware时创建空的作用域
+func getScope(ctx *Context) *requestScope {
+	if v, ok := ctx.Get(scopeKey); ok {
+		return v.(*requestScope)
+	}
+	scope := newScope(nil)
+	ctx.Set(scopeKey, scope)
+	return scope
+}
+
+// scopeMiddleware 创建请求作用域，请求结束时释放依赖
+func (e *Engine) scopeMiddleware() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		scope := newScope(e.scopes)
+		ctx.Set(scopeKey, scope)
+		defer func() {
+			scope.mu.Lock()
+			releases := scope.releases
+			scope.releases = nil
+			scope.mu.Unlock()
+			for i := len(releases) - 1; i >= 0; i-- {
+				releases[i]()
+			}
+		}()
+		ctx.Next()
+	}
+}
@@ -0,0 +1,126 @@
+package hopter
+
+import (
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type (
+	scopeTenant string
+	scopeUser   struct{ tenant scopeTenant }
+	scopeA      struct{}
+	scopeB      struct{}
+)
+
+// newScopeContext 带有请求作用域的Context
+func newScopeContext(e *Engine) *Context {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("GET", "/", nil)
+	c.Set(scopeKey, newScope(e.scopes))
+	return &Context{c}
+}
+
+func TestResolveNested(t *testing.T) {
+	e := newTestEngine()
+	Scoped(e, func(ctx *Context) (scopeTenant, func(), error) {
+		return "acme", nil, nil
+	})
+	Scoped(e, func(ctx *Context) (*scopeUser, func(), error) {
+		tenant, err := Resolve[scopeTenant](ctx)
+		return &scopeUser{tenant}, nil, err
+	})
+	ctx := newScopeContext(e)
+	done := make(chan struct{})
+	var user *scopeUser
+	var err error
+	go func() {
+		user, err = Resolve[*scopeUser](ctx)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("nested Resolve deadlocked")
+	}
+	if err != nil || user.tenant != "acme" {
+		t.Fatalf("Resolve = %+v, %v", user, err)
+	}
+}
+
+func TestResolveCycle(t *testing.T) {
+	e := newTestEngine()
+	Scoped(e, func(ctx *Context) (*scopeA, func(), error) {
+		_, err := Resolve[*scopeB](ctx)
+		return &scopeA{}, nil, err
+	})
+	Scoped(e, func(ctx *Context) (*scopeB, func(), error) {
+		_, err := Resolve[*scopeA](ctx)
+		return &scopeB{}, nil, err
+	})
+	_, err := Resolve[*scopeA](newScopeContext(e))
+	if err == nil || !strings.Contains(err.Error(), "循环") {
+		t.Fatalf("err = %v, want cycle error", err)
+	}
+}
+
+func TestResolveOnce(t *testing.T) {
+	e := newTestEngine()
+	var created, released atomic.Int32
+	Scoped(e, func(ctx *Context) (*scopeA, func(), error) {
+		created.Add(1)
+		time.Sleep(10 * time.Millisecond)
+		return &scopeA{}, func() { released.Add(1) }, nil
+	})
+	ctx := newScopeContext(e)
+	var wg sync.WaitGroup
+	values := make([]*scopeA, 8)
+	for i := range values {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			values[i] = MustResolve[*scopeA](ctx)
+		}()
+	}
+	wg.Wait()
+	if created.Load() != 1 {
+		t.Fatalf("provider called %d times, want 1", created.Load())
+	}
+	for _, v := range values {
+		if v != values[0] {
+			t.Fatal("Resolve returned different instances")
+		}
+	}
+	if n := len(getScope(ctx).releases); n != 1 {
+		t.Fatalf("releases = %d, want 1", n)
+	}
+}
+
+func TestResolveRetryAfterError(t *testing.T) {
+	e := newTestEngine()
+	fail := true
+	Scoped(e, func(ctx *Context) (*scopeA, func(), error) {
+		if fail {
+			return nil, nil, errors.New("unavailable")
+		}
+		return &scopeA{}, nil, nil
+	})
+	ctx := newScopeContext(e)
+	if _, err := Resolve[*scopeA](ctx); err == nil {
+		t.Fatal("Resolve: expected error")
+	}
+	fail = false
+	if _, err := Resolve[*scopeA](ctx); err != nil {
+		t.Fatalf("Resolve after error: %v", err)
+	}
+	Provide(ctx, scopeTenant("provided"), nil)
+	if v, _ := Resolve[scopeTenant](ctx); v != "provided" {
+		t.Fatalf("Provide = %q", v)
+	}
+}
@@ -0,0 +1,186 @@
+package hopter
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// secretPattern 配置中的密钥引用，如${env:DB_PASSWORD}、${file:/run/secrets/db}
+var secretPattern = regexp.MustCompile(`\$\{(\w+):([^}]+)\}`)
+
+var (
+	secretMu        sync.RWMutex
+	secretProviders = map[string]SecretProvider{
+		"env":  SecretProviderFunc(envSecret),
+		"file": SecretProviderFunc(fileSecret),
+	}
+)
+
+// SecretProvider 密钥提供者，按引用获取密钥的值
+type SecretProvider interface {
+	Resolve(ref string) (string, error)
+}
+
+// SecretProviderFunc 函数形式的密钥提供者
+type SecretProviderFunc func(ref string) (string, error)
+
+// Resolve 获取密钥
+func (f SecretProviderFunc) Resolve(ref string) (string, error) {
+	return f(ref)
+}
+
+// RegisterSecretProvider 注册密钥提供者，配置中以${scheme:ref}引用
+// 需要在读取配置文件之前注册，内置env和file两种
+func RegisterSecretProvider(scheme string, provider SecretProvider) {
+	secretMu.Lock()
+	defer secretMu.Unlock()
+	secretProviders[scheme] = provider
+}
+
+// envSecret 从环境变量获取密钥
+func envSecret(ref string) (string, error) {
+	v, ok := os.LookupEnv(ref)
+	if !ok {
+		return "", fmt.Errorf("环境变量%s不存在", ref)
+	}
+	return v, nil
+}
+
+// fileSecret 从文件获取密钥，去掉末尾的换行
+func fileSecret(ref string) (string, error) {
+	b, err := os.ReadFile(ref)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(b), "\r\n"), nil
+}
+
+// resolveSecrets 解析配置中的密钥引用，返回包含密钥的key
+func resolveSecrets(settings map[string]any, prefix string) ([]string, error) {
+	var (
+		keys []string
+		errs []error
+	)
+	for k, v := range settings {
+		key := k
+		if prefix != "" {
+			key = prefix + "." + k
+		}
+		res, secrets, err := resolveSecretValue(v, key)
+		settings[k] = res
+		keys = append(keys, secrets...)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return keys, errors.Join(errs...)
+}
+
+// resolveSecretValue 解析配置值中的密钥引用，列表中引用了密钥时整个列表的key视为密钥
+func resolveSecretValue(v any, key string) (any, []string, error) {
+	switch value := v.(type) {
+	case map[string]any:
+		keys, err := resolveSecrets(value, key)
+		return value, keys, err
+	case []any:
+		var errs []error
+		secret := false
+		for i, item := range value {
+			res, keys, err := resolveSecretValue(item, key)
+			value[i] = res
+			secret = secret || len(keys) > 0
+			if err != nil {
+				errs = append(errs, err)
+			}
+		}
+		if secret {
+			return value, []string{key}, errors.Join(errs...)
+		}
+		return value, nil, errors.Join(errs...)
+	case string:
+		if !secretPattern.MatchString(value) {
+			return value, nil, nil
+		}
+		res, err := resolveSecret(value)
+		if err != nil {
+			return value, nil, fmt.Errorf("%s:%v", key, err)
+		}
+		return res, []string{key}, nil
+	}
+	return v, nil, nil
+}
+
+// resolveSecret 替换字符串中的密钥引用
+func resolveSecret(value string) (string, error) {
+	var errs []error
+	res := secretPattern.ReplaceAllStringFunc(value, func(ref string) string {
+		match := secretPattern.FindStringSubmatch(ref)
+		secretMu.RLock()
+		provider, ok := secretProviders[match[1]]
+		secretMu.RUnlock()
+		if !ok {
+			errs = append(errs, fmt.Errorf("未注册的密钥提供者%s", match[1]))
+			return ref
+		}
+		secret, err := provider.Resolve(match[2])
+		if err != nil {
+			errs = append(errs, fmt.Errorf("获取密钥%s异常,%v", ref, err))
+			return ref
+		}
+		return secret
+	})
+	return res, errors.Join(errs...)
+}
+
+// isSecret key是否为密钥，包括引用了密钥和名称包含敏感关键字的key
+func isSecret(secrets map[string]bool, key string) bool {
+	key = strings.ToLower(key)
+	if secrets[key] {
+		return true
+	}
+	name := key[strings.LastIndex(key, ".")+1:]
+	for _, word := range sensitiveWords {
+		if strings.Contains(name, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactSettings 对配置中的密钥脱敏
+func redactSettings(settings map[string]any, secrets map[string]bool, prefix string) map[string]any {
+	res := make(map[string]any, len(settings))
+	for k, v := range settings {
+		key := k
+		if prefix != "" {
+			key = prefix + "." + k
+		}
+		if m, ok := v.(map[string]any); ok {
+			res[k] = redactSettings(m, secrets, key)
+			continue
+		}
+		res[k] = v
+		if isSecret(secrets, key) {
+			res[k] = redacted
+		}
+	}
+	return res
+}
+
+// Redacted 获取脱敏后的全部配置，用于输出日志或展示
+func (c *config) Redacted() map[string]any {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return redactSettings(c.Viper.AllSettings(), c.secrets, "")
+}
+
+// IsSecret key是否为密钥
+func (c *config) IsSecret(key string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return isSecret(c.secrets, key)
+}
@@ -0,0 +1,42 @@
+package hopter
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResolveSecretsInList(t *testing.T) {
+	t.Setenv("HOPTER_TEST_HASH", "hash")
+	settings := map[string]any{
+		"server": map[string]any{
+			"sessionkeys": []any{
+				[]any{"${env:HOPTER_TEST_HASH}", "block"},
+			},
+			"port": "8000",
+		},
+	}
+	keys, err := resolveSecrets(settings, "")
+	if err != nil {
+		t.Fatalf("resolveSecrets: %v", err)
+	}
+	got := settings["server"].(map[string]any)["sessionkeys"].([]any)[0].([]any)[0]
+	if got != "hash" {
+		t.Fatalf("secret = %v, want hash", got)
+	}
+	if len(keys) != 1 || keys[0] != "server.sessionkeys" {
+		t.Fatalf("keys = %v, want [server.sessionkeys]", keys)
+	}
+}
+
+func TestReadInConfigSecretError(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(file, []byte("db:\n  password: ${env:HOPTER_TEST_MISSING}\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	conf := NewConfig(file, "")
+	conf.ReadInConfig()
+	if conf.readErr() == nil {
+		t.Fatal("readErr = nil, want secret error")
+	}
+}
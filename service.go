@@ -1,16 +1,54 @@
 package hopter
 
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// healthPath 健康检查路径
+const healthPath = "/health"
+
 // Service web对外接口
 type Service interface {
 	Init()
 	Handles(e *Engine)
 }
 
-// Mount 挂载接口
-func (e *Engine) Mount(group string, class ...Service) *Engine {
-	e.group = e.engine.Group(group)
+// Starter 服务启动接口，服务器监听端口成功后按挂载顺序调用
+type Starter interface {
+	Start(ctx context.Context) error
+}
+
+// Stopper 服务停止接口，服务关闭时按挂载的相反顺序调用
+type Stopper interface {
+	Stop(ctx context.Context) error
+}
+
+// HealthChecker 健康检查接口，返回非nil表示服务未就绪
+type HealthChecker interface {
+	Health(ctx context.Context) error
+}
+
+// Mount 挂载接口，返回挂载的路由组
+func (e *Engine) Mount(group string, class ...Service) *Router {
+	return e.mount(&e.engine.RouterGroup, group, class...)
+}
+
+// mount 在parent下挂载服务，Handles期间Engine.Handle注册到该路由组
+func (e *Engine) mount(parent *gin.RouterGroup, group string, class ...Service) *Router {
+	r := e.newRouter(parent, group)
+	prev := e.group
+	e.group = r.group
+	defer func() {
+		e.group = prev
+	}()
 	for _, v := range class {
-		e.beanFactory.Inject(v)
+		// 此时可能还有bean未注册，缺失和歧义的错误在启动时统一报告
+		_ = e.beanFactory.Inject(v)
+		e.injects = append(e.injects, v)
 		e.Beans(v)
 	}
 	for _, v := range class {
@@ -19,5 +57,49 @@ func (e *Engine) Mount(group string, class ...Service) *Engine {
 	for _, v := range class {
 		v.Handles(e)
 	}
-	return e
+	e.services = append(e.services, class...)
+	return r
+}
+
+// start 启动已挂载的服务，并把Stopper注册为关闭钩子
+func (e *Engine) start(ctx context.Context) error {
+	for _, v := range e.services {
+		if s, ok := v.(Starter); ok {
+			if err := s.Start(ctx); err != nil {
+				return fmt.Errorf("web服务启动失败:服务%T启动异常,%v", v, err)
+			}
+		}
+		if s, ok := v.(Stopper); ok {
+			e.OnShutdown(s.Stop)
+		}
+	}
+	e.ready.Store(true)
+	return nil
+}
+
+// health 健康检查处理函数
+func (e *Engine) health(ctx *gin.Context) {
+	status := http.StatusOK
+	services := make(map[string]string)
+	for _, v := range e.services {
+		s, ok := v.(HealthChecker)
+		if !ok {
+			continue
+		}
+		name := fmt.Sprintf("%T", v)
+		if err := s.Health(ctx.Request.Context()); err != nil {
+			status = http.StatusServiceUnavailable
+			services[name] = err.Error()
+			continue
+		}
+		services[name] = "UP"
+	}
+	if !e.ready.Load() {
+		status = http.StatusServiceUnavailable
+	}
+	res := gin.H{"status": "UP", "services": services}
+	if status != http.StatusOK {
+		res["status"] = "DOWN"
+	}
+	ctx.JSON(status, res)
 }
@@ -0,0 +1,126 @@
+package hopter
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// lifecycleSvc 记录启动和停止顺序的服务
+type lifecycleSvc struct {
+	name     string
+	mu       *sync.Mutex
+	events   *[]string
+	startErr error
+	started  chan struct{}
+	release  chan struct{}
+}
+
+func (s *lifecycleSvc) Init()           {}
+func (s *lifecycleSvc) Handles(*Engine) {}
+
+func (s *lifecycleSvc) record(event string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	*s.events = append(*s.events, event+":"+s.name)
+}
+
+func (s *lifecycleSvc) Start(context.Context) error {
+	if s.started != nil {
+		close(s.started)
+		<-s.release
+	}
+	s.record("start")
+	return s.startErr
+}
+
+func (s *lifecycleSvc) Stop(context.Context) error {
+	s.record("stop")
+	return nil
+}
+
+// waitReady 等待服务启动完成
+func waitReady(t *testing.T, e *Engine, done <-chan error) {
+	t.Helper()
+	for !e.ready.Load() {
+		select {
+		case err := <-done:
+			t.Fatalf("RunContext exited: %v", err)
+		case <-time.After(time.Millisecond):
+		}
+	}
+}
+
+func TestServiceStartStopOrder(t *testing.T) {
+	var mu sync.Mutex
+	var events []string
+	e, addr := newTestServer(t, nil)
+	e.Mount("/a", &lifecycleSvc{name: "a", mu: &mu, events: &events})
+	e.Mount("/b", &lifecycleSvc{name: "b", mu: &mu, events: &events})
+	done := runTestServer(t, context.Background(), e, addr)
+	waitReady(t, e, done)
+	if err := e.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	if err := waitRun(t, done); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if got := strings.Join(events, ","); got != "start:a,start:b,stop:b,stop:a" {
+		t.Fatalf("events = %s", got)
+	}
+}
+
+func TestServiceStartFailure(t *testing.T) {
+	var mu sync.Mutex
+	var events []string
+	boom := errors.New("boom")
+	e, _ := newTestServer(t, nil)
+	e.Mount("/a", &lifecycleSvc{name: "a", mu: &mu, events: &events})
+	e.Mount("/b", &lifecycleSvc{name: "b", mu: &mu, events: &events, startErr: boom})
+	e.Mount("/c", &lifecycleSvc{name: "c", mu: &mu, events: &events})
+	err := e.RunContext(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Fatalf("RunContext = %v, want start error", err)
+	}
+	// 启动失败的服务之后的服务不再启动，已启动的服务被停止
+	if got := strings.Join(events, ","); got != "start:a,start:b,stop:a" {
+		t.Fatalf("events = %s", got)
+	}
+}
+
+func TestHealthBeforeReady(t *testing.T) {
+	var mu sync.Mutex
+	var events []string
+	svc := &lifecycleSvc{
+		name: "slow", mu: &mu, events: &events,
+		started: make(chan struct{}), release: make(chan struct{}),
+	}
+	e, addr := newTestServer(t, nil)
+	e.Mount("/slow", svc)
+	done := runTestServer(t, context.Background(), e, addr)
+	<-svc.started
+	resp, err := http.Get(addr + healthPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("health before ready = %d, want 503", resp.StatusCode)
+	}
+	close(svc.release)
+	waitReady(t, e, done)
+	resp, err = http.Get(addr + healthPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("health after ready = %d, want 200", resp.StatusCode)
+	}
+	_ = e.Shutdown(context.Background())
+	_ = waitRun(t, done)
+}
@@ -1,7 +1,12 @@
 package hopter
 
 import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
 	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	ctx "github.com/gorilla/context"
@@ -11,6 +16,76 @@ import (
 // sessionKeyPairs session默认的KeyPairs
 var sessionKeyPairs string = "yostar.com"
 
+// base64KeyPrefix base64编码的session密钥前缀
+const base64KeyPrefix = "base64:"
+
+// ErrDefaultSessionKey 非debug模式下使用了默认的session密钥
+var ErrDefaultSessionKey = errors.New("web服务启动失败:非debug模式下禁止使用默认的session密钥,请配置server.sessionKeys或开启server.debug")
+
+// SessionKeyPairs 读取配置中的session密钥，用于cookie.NewStore
+// server.sessionKeys为[hashKey, blockKey]列表，第一对用于签发新cookie，其余用于密钥轮换期间校验旧cookie，
+// 未配置时使用server.sessionKey，server.debug未开启时使用默认密钥返回ErrDefaultSessionKey
+func SessionKeyPairs(conf Config) ([][]byte, error) {
+	value, err := Bind[ginConfig](conf, "server")
+	if err != nil {
+		return nil, err
+	}
+	pairs := value.SessionKeys
+	if len(pairs) == 0 {
+		pairs = [][]string{{value.SessionKey}}
+	}
+	res := make([][]byte, 0, len(pairs)*2)
+	for i, pair := range pairs {
+		if len(pair) == 0 || len(pair) > 2 {
+			return nil, fmt.Errorf("server.sessionKeys[%d]应为[hashKey, blockKey]", i)
+		}
+		if pair[0] == "" || pair[0] == sessionKeyPairs {
+			if !value.Debug {
+				return nil, ErrDefaultSessionKey
+			}
+			Warn("正在使用默认的session密钥，仅限debug模式")
+		}
+		hashKey, err := decodeSessionKey(pair[0])
+		if err != nil {
+			return nil, fmt.Errorf("server.sessionKeys[%d]的hashKey不合法,%v", i, err)
+		}
+		var blockKey []byte
+		if len(pair) == 2 {
+			if blockKey, err = decodeSessionKey(pair[1]); err != nil {
+				return nil, fmt.Errorf("server.sessionKeys[%d]的blockKey不合法,%v", i, err)
+			}
+			switch len(blockKey) {
+			case 16, 24, 32:
+			default:
+				return nil, fmt.Errorf("server.sessionKeys[%d]的blockKey长度应为16、24或32字节", i)
+			}
+		}
+		res = append(res, hashKey, blockKey)
+	}
+	return res, nil
+}
+
+// decodeSessionKey 解析session密钥，base64:开头的按base64解码
+func decodeSessionKey(key string) ([]byte, error) {
+	if v, ok := strings.CutPrefix(key, base64KeyPrefix); ok {
+		return base64.StdEncoding.DecodeString(v)
+	}
+	return []byte(key), nil
+}
+
+// GenerateSessionKeys 生成随机的hashKey(64字节)和blockKey(32字节)，以base64:格式返回
+func GenerateSessionKeys() (hashKey, blockKey string, err error) {
+	hash := make([]byte, 64)
+	if _, err = rand.Read(hash); err != nil {
+		return "", "", err
+	}
+	block := make([]byte, 32)
+	if _, err = rand.Read(block); err != nil {
+		return "", "", err
+	}
+	return base64KeyPrefix + base64.StdEncoding.EncodeToString(hash), base64KeyPrefix + base64.StdEncoding.EncodeToString(block), nil
+}
+
 // Store 存储接口
 type Store interface {
 	sessions.Store
@@ -142,8 +217,9 @@ func sessionsMany(store Store, names ...string) gin.HandlerFunc {
 	}
 }
 
-// SetSessionsStore Sessions存储
+// SetSessionsStore Sessions存储，启动时检查session密钥，非debug模式下禁止使用默认密钥
 func (e *Engine) SetSessionsStore(store Store, names ...string) *Engine {
+	e.sessions = true
 	e.engine.Use(sessionsMany(store, names...))
 	return e
 }
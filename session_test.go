@@ -0,0 +1,17 @@
+package hopter
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSessionKeyPairsDebug(t *testing.T) {
+	conf := NewConfig("", "")
+	if _, err := SessionKeyPairs(conf); !errors.Is(err, ErrDefaultSessionKey) {
+		t.Fatalf("err = %v, want ErrDefaultSessionKey", err)
+	}
+	conf.Set("server.debug", true)
+	if _, err := SessionKeyPairs(conf); err != nil {
+		t.Fatalf("debug mode: %v", err)
+	}
+}
@@ -15,6 +15,16 @@ func NewStore(keyPairs ...[]byte) Store {
 	return &store{sessions.NewCookieStore(keyPairs...)}
 }
 
+// NewStoreFromConfig 使用配置中的server.sessionKeys创建存储
+// server.debug未开启时使用默认密钥返回web.ErrDefaultSessionKey
+func NewStoreFromConfig(conf web.Config) (Store, error) {
+	keyPairs, err := web.SessionKeyPairs(conf)
+	if err != nil {
+		return nil, err
+	}
+	return NewStore(keyPairs...), nil
+}
+
 // store store结构体
 type store struct {
 	*sessions.CookieStore
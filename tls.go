@@ -0,0 +1,191 @@
+package hopter
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// tlsVersions 支持的TLS最低版本
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// tlsConfig TLS配置
+type tlsConfig struct {
+	// 证书文件
+	CertFile string `mapstructure:"certFile" validate:"required_with=KeyFile"`
+	// 私钥文件
+	KeyFile string `mapstructure:"keyFile" validate:"required_with=CertFile"`
+	// 客户端CA证书，设置后开启双向认证
+	ClientCAFile string `mapstructure:"clientCAFile"`
+	// 最低TLS版本 1.0|1.1|1.2|1.3
+	MinVersion string `mapstructure:"minVersion" validate:"omitempty,oneof=1.0 1.1 1.2 1.3"`
+	// 加密套件，按优先顺序排列，为空使用go默认值
+	CipherSuites []string `mapstructure:"cipherSuites"`
+	// 是否禁用HTTP/2
+	DisableHTTP2 bool `mapstructure:"disableHTTP2"`
+}
+
+// Enabled 是否开启TLS
+func (c *tlsConfig) Enabled() bool {
+	return c.CertFile != "" && c.KeyFile != ""
+}
+
+// build 生成tls.Config，证书文件变化时自动重新加载
+func (c *tlsConfig) build() (*tls.Config, error) {
+	reloader, err := newCertReloader(c.CertFile, c.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+	res := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: reloader.GetCertificate,
+		NextProtos:     []string{"h2", "http/1.1"},
+	}
+	if c.DisableHTTP2 {
+		res.NextProtos = []string{"http/1.1"}
+	}
+	if c.MinVersion != "" {
+		version, ok := tlsVersions[c.MinVersion]
+		if !ok {
+			return nil, fmt.Errorf("不支持的TLS版本:%s", c.MinVersion)
+		}
+		res.MinVersion = version
+	}
+	if len(c.CipherSuites) > 0 {
+		suites := make(map[string]uint16)
+		for _, v := range tls.CipherSuites() {
+			suites[v.Name] = v.ID
+		}
+		for _, name := range c.CipherSuites {
+			id, ok := suites[strings.ToUpper(name)]
+			if !ok {
+				return nil, fmt.Errorf("不支持的加密套件:%s", name)
+			}
+			res.CipherSuites = append(res.CipherSuites, id)
+		}
+	}
+	if c.ClientCAFile != "" {
+		b, err := os.ReadFile(c.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("读取客户端CA证书异常,%v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(b) {
+			return nil, fmt.Errorf("解析客户端CA证书异常:%s", c.ClientCAFile)
+		}
+		res.ClientCAs = pool
+		res.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return res, nil
+}
+
+// applyTLS 为http.Server设置TLS
+func applyTLS(server *http.Server, conf *tlsConfig) error {
+	if !conf.Enabled() {
+		server.TLSConfig = nil
+		return nil
+	}
+	value, err := conf.build()
+	if err != nil {
+		return fmt.Errorf("web服务启动失败:TLS配置异常,%v", err)
+	}
+	server.TLSConfig = value
+	if conf.DisableHTTP2 {
+		// 非nil的空map会关闭http.Server自动启用的HTTP/2
+		server.TLSNextProto = make(map[string]func(*http.Server, *tls.Conn, http.Handler))
+	}
+	return nil
+}
+
+// certReloader 证书热加载
+type certReloader struct {
+	certFile string
+	keyFile  string
+
+	mu      sync.RWMutex
+	cert    *tls.Certificate
+	modTime time.Time
+	// checked 上次检查证书文件的时间(UnixNano)，握手时最多每certCheckInterval检查一次
+	checked atomic.Int64
+}
+
+// certCheckInterval 检查证书文件是否变化的最小间隔
+const certCheckInterval = 10 * time.Second
+
+// newCertReloader 创建证书热加载
+func newCertReloader(certFile, keyFile string) (*certReloader, error) {
+	res := &certReloader{certFile: certFile, keyFile: keyFile}
+	if err := res.reload(); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// lastModTime 证书和私钥中最新的修改时间
+func (r *certReloader) lastModTime() (time.Time, error) {
+	var res time.Time
+	for _, name := range []string{r.certFile, r.keyFile} {
+		info, err := os.Stat(name)
+		if err != nil {
+			return res, err
+		}
+		if info.ModTime().After(res) {
+			res = info.ModTime()
+		}
+	}
+	return res, nil
+}
+
+// reload 重新加载证书
+func (r *certReloader) reload() error {
+	modTime, err := r.lastModTime()
+	if err != nil {
+		return fmt.Errorf("读取证书文件异常,%v", err)
+	}
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("加载证书异常,%v", err)
+	}
+	r.mu.Lock()
+	r.cert = &cert
+	r.modTime = modTime
+	r.mu.Unlock()
+	r.checked.Store(time.Now().UnixNano())
+	return nil
+}
+
+// GetCertificate 提供给tls.Config，证书文件有变化时重新加载，加载失败继续使用旧证书
+// 文件检查有间隔限制，替换证书后最多certCheckInterval生效
+func (r *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	cert, last := r.cert, r.modTime
+	r.mu.RUnlock()
+	now := time.Now().UnixNano()
+	checked := r.checked.Load()
+	// 间隔内或其他握手正在检查时直接使用当前证书
+	if now-checked < int64(certCheckInterval) || !r.checked.CompareAndSwap(checked, now) {
+		return cert, nil
+	}
+	if modTime, err := r.lastModTime(); err == nil && modTime.After(last) {
+		if err := r.reload(); err != nil {
+			Warn("证书热加载异常,继续使用旧证书:%v", err)
+			return cert, nil
+		}
+		Info("证书已重新加载:%s", r.certFile)
+		r.mu.RLock()
+		cert = r.cert
+		r.mu.RUnlock()
+	}
+	return cert, nil
+}
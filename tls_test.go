@@ -0,0 +1,194 @@
+package hopter
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testCert 测试用证书
+type testCert struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	certPEM []byte
+	keyPEM  []byte
+}
+
+// newTestCert 生成证书，parent为nil时生成自签名CA证书
+func newTestCert(t *testing.T, name string, parent *testCert, usage x509.ExtKeyUsage) *testCert {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial, _ := rand.Int(rand.Reader, big.NewInt(1<<62))
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	signer, signerKey := tmpl, key
+	if parent == nil {
+		tmpl.IsCA = true
+		tmpl.BasicConstraintsValid = true
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCert{
+		cert:    cert,
+		key:     key,
+		certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		keyPEM:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+	}
+}
+
+// writeFiles 写入证书和私钥文件
+func (c *testCert) writeFiles(t *testing.T, dir, name string) (string, string) {
+	t.Helper()
+	certFile := filepath.Join(dir, name+".crt")
+	keyFile := filepath.Join(dir, name+".key")
+	if err := os.WriteFile(certFile, c.certPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, c.keyPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+func TestCertReloader(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCert(t, "ca", nil, x509.ExtKeyUsageAny)
+	first := newTestCert(t, "first", ca, x509.ExtKeyUsageServerAuth)
+	certFile, keyFile := first.writeFiles(t, dir, "server")
+	r, err := newCertReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second := newTestCert(t, "second", ca, x509.ExtKeyUsageServerAuth)
+	second.writeFiles(t, dir, "server")
+	future := time.Now().Add(time.Minute)
+	for _, name := range []string{certFile, keyFile} {
+		if err := os.Chtimes(name, future, future); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 检查间隔内继续使用旧证书
+	cert, _ := r.GetCertificate(nil)
+	if !bytes.Equal(cert.Certificate[0], first.cert.Raw) {
+		t.Fatal("certificate reloaded before check interval")
+	}
+	r.checked.Store(0)
+	cert, _ = r.GetCertificate(nil)
+	if !bytes.Equal(cert.Certificate[0], second.cert.Raw) {
+		t.Fatal("certificate was not reloaded")
+	}
+	// 文件损坏时继续使用旧证书
+	if err := os.WriteFile(certFile, []byte("broken"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	later := future.Add(time.Minute)
+	_ = os.Chtimes(certFile, later, later)
+	r.checked.Store(0)
+	cert, _ = r.GetCertificate(nil)
+	if !bytes.Equal(cert.Certificate[0], second.cert.Raw) {
+		t.Fatal("broken certificate replaced the current one")
+	}
+}
+
+func TestTLSConfigInvalid(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCert(t, "ca", nil, x509.ExtKeyUsageAny)
+	certFile, keyFile := newTestCert(t, "server", ca, x509.ExtKeyUsageServerAuth).writeFiles(t, dir, "server")
+	if _, err := (&tlsConfig{CertFile: certFile, KeyFile: keyFile, MinVersion: "1.4"}).build(); err == nil {
+		t.Fatal("build: expected error for minVersion 1.4")
+	}
+	if _, err := (&tlsConfig{CertFile: certFile, KeyFile: keyFile, CipherSuites: []string{"TLS_FOO"}}).build(); err == nil {
+		t.Fatal("build: expected error for unknown cipher suite")
+	}
+	conf := NewConfig("", "")
+	conf.Set("server.tls.minVersion", "1.4")
+	if _, err := Bind[ginConfig](conf, "server"); err == nil {
+		t.Fatal("Bind: expected validation error for minVersion 1.4")
+	}
+	value, err := (&tlsConfig{
+		CertFile:     certFile,
+		KeyFile:      keyFile,
+		MinVersion:   "1.3",
+		CipherSuites: []string{"tls_ecdhe_ecdsa_with_aes_128_gcm_sha256"},
+	}).build()
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	if value.MinVersion != tls.VersionTLS13 || len(value.CipherSuites) != 1 {
+		t.Fatalf("tls.Config = %x, %v", value.MinVersion, value.CipherSuites)
+	}
+}
+
+func TestMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCert(t, "ca", nil, x509.ExtKeyUsageAny)
+	certFile, keyFile := newTestCert(t, "server", ca, x509.ExtKeyUsageServerAuth).writeFiles(t, dir, "server")
+	caFile := filepath.Join(dir, "ca.crt")
+	if err := os.WriteFile(caFile, ca.certPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	e, addr := newTestServer(t, map[string]any{
+		"server.tls.certFile":     certFile,
+		"server.tls.keyFile":      keyFile,
+		"server.tls.clientCAFile": caFile,
+	})
+	done := runTestServer(t, context.Background(), e, addr)
+	defer func() {
+		_ = e.Shutdown(context.Background())
+		_ = waitRun(t, done)
+	}()
+	url := "https" + addr[len("http"):] + healthPath
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+	client := func(certs ...tls.Certificate) *http.Client {
+		return &http.Client{Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{RootCAs: pool, Certificates: certs},
+		}}
+	}
+	if resp, err := client().Get(url); err == nil {
+		resp.Body.Close()
+		t.Fatal("request without client certificate succeeded")
+	}
+	clientCert := newTestCert(t, "client", ca, x509.ExtKeyUsageClientAuth)
+	pair, err := tls.X509KeyPair(clientCert.certPEM, clientCert.keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client(pair).Get(url)
+	if err != nil {
+		t.Fatalf("request with client certificate: %v", err)
+	}
+	resp.Body.Close()
+}
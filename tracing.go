@@ -0,0 +1,184 @@
+package hopter
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// tracerName 服务端span的instrumentation名称
+	tracerName = "github.com/allposs/hopter"
+	// TraceIDField 日志中trace ID的字段名
+	TraceIDField = "traceId"
+	// SpanIDField 日志中span ID的字段名
+	SpanIDField = "spanId"
+)
+
+// traceConfig 链路追踪配置参数
+type traceConfig struct {
+	// 是否开启链路追踪
+	Enable bool `mapstructure:"enable"`
+	// 服务名称
+	ServiceName string `mapstructure:"serviceName" default:"hopter"`
+	// 导出方式 otlp|file
+	Exporter string `mapstructure:"exporter" default:"otlp" validate:"oneof=otlp file"`
+	// OTLP HTTP地址，如"localhost:4318"，为空时使用OTEL_EXPORTER_OTLP_ENDPOINT
+	Endpoint string `mapstructure:"endpoint"`
+	// OTLP是否不使用TLS
+	Insecure bool `mapstructure:"insecure"`
+	// file导出的文件路径，用于本地调试和测试
+	File string `mapstructure:"file" default:"./logs/trace.json"`
+	// 采样比例，0~1，上游已采样的请求总是采样
+	SampleRate float64 `mapstructure:"sampleRate" default:"1" validate:"gte=0,lte=1"`
+}
+
+// propagator W3C traceparent传播
+var propagator = propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+
+// initTrace 初始化链路追踪，未开启时返回nil
+func (e *Engine) initTrace(conf Config) (gin.HandlerFunc, error) {
+	value, err := Bind[traceConfig](conf, "trace")
+	if err != nil {
+		return nil, err
+	}
+	if !value.Enable {
+		return nil, nil
+	}
+	exporter, err := e.newTraceExporter(&value)
+	if err != nil {
+		return nil, fmt.Errorf("链路追踪初始化异常,%v", err)
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(semconv.ServiceName(value.ServiceName)))
+	if err != nil {
+		return nil, fmt.Errorf("链路追踪初始化异常,%v", err)
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(value.SampleRate))),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagator)
+	// 关闭时导出剩余的span
+	e.OnShutdown(provider.Shutdown)
+	return tracing(provider.Tracer(tracerName)), nil
+}
+
+// newTraceExporter 创建span导出器，file导出的文件在span导出完成后关闭
+func (e *Engine) newTraceExporter(value *traceConfig) (sdktrace.SpanExporter, error) {
+	if value.Exporter == "file" {
+		if err := makeDirAll(value.File); err != nil {
+			return nil, err
+		}
+		f, err := os.OpenFile(value.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, err
+		}
+		e.OnShutdown(func(context.Context) error {
+			return f.Close()
+		})
+		return stdouttrace.New(stdouttrace.WithWriter(f))
+	}
+	var opts []otlptracehttp.Option
+	if value.Endpoint != "" {
+		opts = append(opts, otlptracehttp.WithEndpoint(value.Endpoint))
+	}
+	if value.Insecure {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	}
+	return otlptracehttp.New(context.Background(), opts...)
+}
+
+// tracing 每个请求创建服务端span，从traceparent请求头继续上游的链路
+func tracing(tracer trace.Tracer) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		r := ctx.Request
+		parent := propagator.Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		spanCtx, span := tracer.Start(parent, r.Method,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(r.Method),
+				semconv.URLPath(r.URL.Path),
+				semconv.ClientAddress(ctx.ClientIP()),
+				semconv.UserAgentOriginal(r.UserAgent()),
+				attribute.String("http.request.id", requestID(ctx)),
+			),
+		)
+		defer span.End()
+		ctx.Request = r.WithContext(spanCtx)
+		ctx.Next()
+		status := ctx.Writer.Status()
+		// 路由匹配后才知道路由模板
+		if route := ctx.FullPath(); route != "" {
+			span.SetName(r.Method + " " + route)
+			span.SetAttributes(semconv.HTTPRoute(route))
+		}
+		span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+		if len(ctx.Errors) > 0 {
+			span.SetStatus(codes.Error, ctx.Errors.String())
+		}
+	}
+}
+
+// Span 得到当前请求的span，未开启链路追踪时返回不记录的span
+func (ctx *Context) Span() trace.Span {
+	return trace.SpanFromContext(ctx.Request.Context())
+}
+
+// PropagateTrace 把ctx中的链路写入请求头，调用下游服务时使用
+func PropagateTrace(ctx context.Context, header http.Header) {
+	propagator.Inject(ctx, propagation.HeaderCarrier(header))
+}
+
+// traceHook 日志条目带有context时写入trace ID和span ID
+type traceHook struct{}
+
+func (traceHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (traceHook) Fire(entry *logrus.Entry) error {
+	if entry.Context == nil {
+		return nil
+	}
+	sc := trace.SpanContextFromContext(entry.Context)
+	if !sc.IsValid() {
+		return nil
+	}
+	entry.Data[TraceIDField] = sc.TraceID().String()
+	entry.Data[SpanIDField] = sc.SpanID().String()
+	return nil
+}
+
+// traceExemplar 请求耗时直方图的exemplar，采样的请求带上trace ID
+func traceExemplar(ctx *gin.Context) prometheus.Labels {
+	return contextExemplar(ctx.Request.Context())
+}
+
+// contextExemplar ctx中的span已采样时返回带trace ID的exemplar
+func contextExemplar(ctx context.Context) prometheus.Labels {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsSampled() {
+		return nil
+	}
+	return prometheus.Labels{"trace_id": sc.TraceID().String()}
+}
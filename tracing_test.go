@@ -0,0 +1,97 @@
+package hopter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// exportedSpan file导出的span
+type exportedSpan struct {
+	Name        string
+	SpanContext struct {
+		TraceID string
+		SpanID  string
+	}
+	Parent struct {
+		TraceID string
+		SpanID  string
+	}
+}
+
+func TestTraceparentContinued(t *testing.T) {
+	const (
+		traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+		spanID  = "00f067aa0ba902b7"
+	)
+	traceFile := filepath.Join(t.TempDir(), "trace.json")
+	logDir := t.TempDir()
+	e, addr := newTestServer(t, map[string]any{
+		"log.path":       filepath.Join(logDir, "server.log"),
+		"trace.enable":   true,
+		"trace.exporter": "file",
+		"trace.file":     traceFile,
+	})
+	e.Handle("GET", "/users/:id", func(ctx *Context) Message {
+		ctx.Logger().Info("traced request")
+		ctx.Status(http.StatusOK)
+		return nil
+	})
+	done := runTestServer(t, context.Background(), e, addr)
+	req, _ := http.NewRequest(http.MethodGet, addr+"/users/1", nil)
+	req.Header.Set("traceparent", "00-"+traceID+"-"+spanID+"-01")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	// 关闭时导出剩余的span并关闭日志文件
+	if err := e.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	_ = waitRun(t, done)
+
+	f, err := os.Open(traceFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var span *exportedSpan
+	for dec := json.NewDecoder(f); ; {
+		var v exportedSpan
+		if err := dec.Decode(&v); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		if v.Name == "GET /users/:id" {
+			span = &v
+		}
+	}
+	if span == nil {
+		t.Fatal("server span was not exported")
+	}
+	if span.SpanContext.TraceID != traceID || span.Parent.SpanID != spanID {
+		t.Fatalf("span = %+v, want trace %s parent %s", span, traceID, spanID)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(logDir, "*"))
+	var line string
+	for _, file := range files {
+		b, _ := os.ReadFile(file)
+		for _, v := range strings.Split(string(b), "\n") {
+			if strings.Contains(v, "traced request") {
+				line = v
+			}
+		}
+	}
+	if !strings.Contains(line, TraceIDField+"="+traceID) || !strings.Contains(line, SpanIDField+"="+span.SpanContext.SpanID) {
+		t.Fatalf("log line %q does not carry the trace", line)
+	}
+}
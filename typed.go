@@ -0,0 +1,238 @@
+package hopter
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/textproto"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+var bindingOnce sync.Once
+
+// typedOperation 接口的请求和响应类型
+type typedOperation struct {
+	req  reflect.Type
+	resp reflect.Type
+}
+
+// Types 接口的请求和响应类型，用于生成OpenAPI文档的参数、请求体和响应，
+// 用于没有使用Typed的处理函数，Typed创建的处理函数通过HandleTyped注册时自带类型
+func Types[Req, Resp any]() RouteOption {
+	return func(spec *routeSpec) {
+		spec.typed = &typedOperation{reflect.TypeFor[Req](), reflect.TypeFor[Resp]()}
+	}
+}
+
+// FieldError 请求参数校验失败的字段
+type FieldError struct {
+	// 字段名，取json、form、uri、header标签
+	Field string `json:"field" xml:"field" yaml:"field"`
+	// 校验规则
+	Rule string `json:"rule" xml:"rule" yaml:"rule"`
+	// 规则参数
+	Param string `json:"param,omitempty" xml:"param,omitempty" yaml:"param,omitempty"`
+}
+
+// TypedHandler Typed创建的处理函数，带有请求和响应类型，通过HandleTyped注册时用于生成OpenAPI文档
+type TypedHandler struct {
+	handler HandlerFunc
+	typed   *typedOperation
+}
+
+// payload 把任意值包装为Message
+type payload struct {
+	value any
+}
+
+// Send Message接口实现
+func (p payload) Send(Context) any {
+	return p.value
+}
+
+// Typed 把强类型处理函数转换为TypedHandler，通过HandleTyped注册
+// 请求依次从query(form标签)、body(按Content-Type)、header(header标签)、路径参数(uri标签)绑定到Req，
+// query、header和路径参数只绑定带有对应标签的字段，再按binding标签统一校验，
+// 类型转换或校验失败返回400并列出每个不合法的字段；
+// Resp实现Message时直接返回，否则作为响应体
+func Typed[Req, Resp any](fn func(ctx *Context, req Req) (Resp, error)) *TypedHandler {
+	bindingOnce.Do(registerFieldName)
+	handler := func(ctx *Context) Message {
+		var req Req
+		if err := bindRequest(ctx, &req); err != nil {
+			return ctx.Fail(err)
+		}
+		resp, err := fn(ctx, req)
+		if err != nil {
+			return ctx.Fail(err)
+		}
+		if msg, ok := any(resp).(Message); ok {
+			return msg
+		}
+		return payload{resp}
+	}
+	return &TypedHandler{handler, &typedOperation{reflect.TypeFor[Req](), reflect.TypeFor[Resp]()}}
+}
+
+// bindRequest 绑定并校验请求，类型转换失败和校验失败的字段一起返回
+func bindRequest(ctx *Context, req any) error {
+	var details []FieldError
+	v := reflect.ValueOf(req).Elem()
+	query := requestSource{"form", ctx.Request.URL.Query()}
+	header := requestSource{"header", ctx.Request.Header}
+	uri := requestSource{"uri", make(map[string][]string, len(ctx.Params))}
+	for _, p := range ctx.Params {
+		uri.values[p.Key] = []string{p.Value}
+	}
+	query.bind(v, "", &details)
+	if hasBody(ctx.Request) {
+		// 请求体单独绑定时忽略校验错误，全部绑定后再统一校验
+		err := ctx.ShouldBindWith(req, binding.Default(ctx.Request.Method, ctx.ContentType()))
+		var validationErrors validator.ValidationErrors
+		var typeError *json.UnmarshalTypeError
+		switch {
+		case err == nil, errors.As(err, &validationErrors):
+		case errors.As(err, &typeError):
+			details = append(details, FieldError{Field: typeError.Field, Rule: "type"})
+		default:
+			return ErrBadRequest.WithDetails(err.Error()).Wrap(err)
+		}
+	}
+	header.bind(v, "", &details)
+	uri.bind(v, "", &details)
+	var err error
+	if binding.Validator != nil {
+		err = binding.Validator.ValidateStruct(req)
+	}
+	var validationErrors validator.ValidationErrors
+	if err != nil && !errors.As(err, &validationErrors) {
+		return ErrBadRequest.WithDetails(err.Error()).Wrap(err)
+	}
+	failed := make(map[string]bool, len(details))
+	for _, f := range details {
+		failed[f.Field] = true
+	}
+	if len(details) > 0 {
+		err = errors.Join(err, errBindType)
+	}
+	for _, v := range validationErrors {
+		// Namespace以结构体类型名开头
+		_, field, _ := strings.Cut(v.Namespace(), ".")
+		// 类型转换失败的字段不再重复报告校验错误
+		if !failed[field] {
+			details = append(details, FieldError{Field: field, Rule: v.Tag(), Param: v.Param()})
+		}
+	}
+	if len(details) == 0 {
+		return nil
+	}
+	return ErrBadRequest.WithDetails(details).Wrap(err)
+}
+
+// errBindType 请求参数类型转换失败
+var errBindType = errors.New("请求参数类型转换失败")
+
+// requestSource 请求参数来源，values的键为参数名
+type requestSource struct {
+	tag    string
+	values map[string][]string
+}
+
+// bind 把参数绑定到带有tag标签的字段，返回是否设置了字段
+// 没有标签的字段不按字段名绑定，避免header、query等来源覆盖请求体中的值；
+// 类型转换失败的字段加入details
+func (s requestSource) bind(v reflect.Value, prefix string, details *[]FieldError) bool {
+	set := false
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get(s.tag)
+		if !field.IsExported() || tag == "-" {
+			continue
+		}
+		value := v.Field(i)
+		name := prefix + fieldName(field)
+		if nested(field.Type) {
+			if value.Kind() != reflect.Ptr {
+				set = s.bind(value, name+".", details) || set
+				continue
+			}
+			// 指针只在有参数时创建
+			elem := value
+			if value.IsNil() {
+				elem = reflect.New(field.Type.Elem())
+			}
+			if s.bind(elem.Elem(), name+".", details) {
+				value.Set(elem)
+				set = true
+			}
+			continue
+		}
+		key, opts, _ := strings.Cut(tag, ",")
+		if key == "" {
+			continue
+		}
+		values, ok := s.values[key]
+		if s.tag == "header" {
+			values, ok = s.values[textproto.CanonicalMIMEHeaderKey(key)]
+		}
+		if !ok && !strings.Contains(opts, "default=") {
+			continue
+		}
+		// 只含该字段的结构体，保留time_format等标签，转换错误可以对应到字段
+		single := reflect.New(reflect.StructOf([]reflect.StructField{{Name: "V", Type: field.Type, Tag: field.Tag}}))
+		form := make(map[string][]string, 1)
+		if ok {
+			form[key] = values
+		}
+		if err := binding.MapFormWithTag(single.Interface(), form, s.tag); err != nil {
+			*details = append(*details, FieldError{Field: name, Rule: "type"})
+			continue
+		}
+		value.Set(single.Elem().Field(0))
+		set = true
+	}
+	return set
+}
+
+// nested 是否为需要逐个字段绑定的结构体
+func nested(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || t == reflect.TypeFor[time.Time]() {
+		return false
+	}
+	return !reflect.PointerTo(t).Implements(reflect.TypeFor[binding.BindUnmarshaler]())
+}
+
+// hasBody 请求是否带有请求体
+func hasBody(r *http.Request) bool {
+	return r.Body != nil && r.Body != http.NoBody && r.ContentLength != 0
+}
+
+// registerFieldName 校验错误中的字段名使用请求中的参数名
+func registerFieldName() {
+	v, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return
+	}
+	v.RegisterTagNameFunc(fieldName)
+}
+
+// fieldName 字段在请求中的参数名，依次取json、form、uri、header标签，忽略"-"
+func fieldName(field reflect.StructField) string {
+	for _, tag := range []string{"json", "form", "uri", "header"} {
+		name, _, _ := strings.Cut(field.Tag.Get(tag), ",")
+		if name != "" && name != "-" {
+			return name
+		}
+	}
+	return field.Name
+}
@@ -0,0 +1,117 @@
+package hopter
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newTestEngine 不读取配置文件、不初始化日志的Engine
+func newTestEngine() *Engine {
+	e := &Engine{engine: gin.New(), beanFactory: NewBeanFactory(), scopes: make(map[reflect.Type]scopeProvider)}
+	e.group = &e.engine.RouterGroup
+	e.Endpoint = &Endpoint{config: NewConfig("", "")}
+	return e
+}
+
+type typedUser struct {
+	OrgID int    `uri:"org" binding:"required"`
+	Name  string `json:"name" binding:"required"`
+}
+
+func TestHandleTyped(t *testing.T) {
+	e := newTestEngine()
+	e.HandleTyped("POST", "/orgs/:org/users", Typed(func(ctx *Context, req typedUser) (*typedUser, error) {
+		return &req, nil
+	}))
+	e.Handle("GET", "/ping", func(*Context) Message {
+		return nil
+	})
+	doc, err := e.OpenAPI()
+	if err != nil {
+		t.Fatalf("OpenAPI: %v", err)
+	}
+	op := doc.Paths["/orgs/{org}/users"]["post"]
+	if len(op.Parameters) != 1 || op.Parameters[0].In != "path" || op.RequestBody == nil || op.Responses["200"].Content == nil {
+		t.Fatalf("typed operation = %+v", op)
+	}
+	if op := doc.Paths["/ping"]["get"]; op.Responses["200"].Content != nil {
+		t.Fatal("route without Types should not have a response schema")
+	}
+}
+
+type bindSources struct {
+	Name  string `json:"name"`
+	Role  string `json:"role"`
+	Token string `header:"X-Token"`
+	Page  int    `form:"page"`
+	Org   int    `uri:"org"`
+}
+
+func TestBindOnlyTaggedFields(t *testing.T) {
+	e := newTestEngine()
+	var got bindSources
+	e.HandleTyped("POST", "/orgs/:org", Typed(func(ctx *Context, req bindSources) (*bindSources, error) {
+		got = req
+		return &req, nil
+	}))
+	req := httptest.NewRequest("POST", "/orgs/7?page=2&Role=admin&role=admin&Name=x", strings.NewReader(`{"name":"a","role":"user"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Role", "admin")
+	req.Header.Set("Name", "x")
+	req.Header.Set("X-Token", "t")
+	w := httptest.NewRecorder()
+	e.engine.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+	}
+	// 没有form、header标签的字段不能被query和header覆盖
+	want := bindSources{Name: "a", Role: "user", Token: "t", Page: 2, Org: 7}
+	if got != want {
+		t.Fatalf("req = %+v, want %+v", got, want)
+	}
+}
+
+type bindTypes struct {
+	Page  int    `form:"page"`
+	Count int    `json:"count"`
+	Age   int    `header:"X-Age"`
+	Name  string `json:"name" binding:"required"`
+	Size  int    `form:"size" binding:"min=1"`
+}
+
+func TestBindCollectsTypeErrors(t *testing.T) {
+	e := newTestEngine()
+	e.HandleTyped("POST", "/items", Typed(func(ctx *Context, req bindTypes) (*bindTypes, error) {
+		return &req, nil
+	}))
+	req := httptest.NewRequest("POST", "/items?page=abc&size=x", strings.NewReader(`{"count":"n"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Age", "old")
+	w := httptest.NewRecorder()
+	e.engine.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400", w.Code)
+	}
+	var body struct {
+		Details []FieldError `json:"details"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	want := []FieldError{
+		{Field: "page", Rule: "type"},
+		{Field: "size", Rule: "type"},
+		{Field: "count", Rule: "type"},
+		{Field: "X-Age", Rule: "type"},
+		{Field: "name", Rule: "required"},
+	}
+	if !reflect.DeepEqual(body.Details, want) {
+		t.Fatalf("details = %+v, want %+v", body.Details, want)
+	}
+}
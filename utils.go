@@ -5,7 +5,6 @@ import (
 	"os"
 	"path"
 	"runtime"
-	"runtime/debug"
 
 	"github.com/gin-gonic/gin"
 )
@@ -49,8 +48,11 @@ func recovered() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		defer func() {
 			if e := recover(); any(e) != nil {
-				ctx.AbortWithStatusJSON(400, gin.H{"web服务异常:%v,请联系管理人员": e})
-				debug.PrintStack()
+				err, ok := e.(error)
+				if !ok {
+					err = fmt.Errorf("panic: %v", e)
+				}
+				abortWithError(&Context{ctx}, err)
 			}
 		}()
 		ctx.Next()
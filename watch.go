@@ -0,0 +1,157 @@
+package hopter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/viper"
+)
+
+// reloadDebounce 配置文件变化后等待的时间，合并编辑器的多次写入
+const reloadDebounce = 500 * time.Millisecond
+
+// validatorRegistry 支持注册校验函数的配置
+type validatorRegistry interface {
+	addValidator(key string, fn func(Config) error)
+}
+
+// addValidator 注册key对应的校验函数
+func (c *config) addValidator(key string, fn func(Config) error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.validators[key] = fn
+}
+
+// OnChange 订阅配置变更
+func (c *config) OnChange(key string, fn func(old, new any)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.listeners = append(c.listeners, listener{key, fn})
+}
+
+// Watch 监听配置文件变化并热加载
+func (c *config) Watch(ctx context.Context) error {
+	c.mu.RLock()
+	files := make(map[string]bool, len(c.layers))
+	for _, v := range c.layers {
+		if file, err := filepath.Abs(v.file); err == nil {
+			files[file] = true
+		}
+	}
+	c.mu.RUnlock()
+	if len(files) == 0 {
+		return nil
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("配置文件监听异常,%v", err)
+	}
+	// 监听目录，兼容编辑器和k8s ConfigMap以替换文件的方式更新
+	for file := range files {
+		if err := watcher.Add(filepath.Dir(file)); err != nil {
+			_ = watcher.Close()
+			return fmt.Errorf("配置文件监听异常,%v", err)
+		}
+	}
+	go func() {
+		defer watcher.Close()
+		var timer *time.Timer
+		for {
+			select {
+			case <-ctx.Done():
+				if timer != nil {
+					timer.Stop()
+				}
+				return
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if !files[filepath.Clean(event.Name)] && !isSymlinkEvent(event) {
+					continue
+				}
+				if timer != nil {
+					timer.Stop()
+				}
+				timer = time.AfterFunc(reloadDebounce, func() {
+					if err := c.reload(); err != nil {
+						Error("配置热加载失败,继续使用旧配置:%v", err)
+					}
+				})
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				Warn("配置文件监听异常:%v", err)
+			}
+		}
+	}()
+	return nil
+}
+
+// isSymlinkEvent k8s ConfigMap通过替换..data软链接更新文件
+func isSymlinkEvent(event fsnotify.Event) bool {
+	return filepath.Base(event.Name) == "..data" && event.Has(fsnotify.Create)
+}
+
+// reload 重新读取配置文件，校验通过后替换配置并通知订阅者
+func (c *config) reload() error {
+	c.mu.RLock()
+	files := make([]string, 0, len(c.layers))
+	for _, v := range c.layers {
+		files = append(files, v.file)
+	}
+	c.mu.RUnlock()
+	layers, err := readLayers(files)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	old := c.Viper.AllSettings()
+	oldLayers := c.layers
+	if err := c.applyLayers(layers); err != nil {
+		_ = c.applyLayers(oldLayers)
+		c.mu.Unlock()
+		return fmt.Errorf("解析配置文件异常,%v", err)
+	}
+	current := c.Viper.AllSettings()
+	if err := c.validate(current); err != nil {
+		// 恢复旧配置
+		_ = c.applyLayers(oldLayers)
+		c.mu.Unlock()
+		return err
+	}
+	listeners := append([]listener(nil), c.listeners...)
+	c.mu.Unlock()
+	Info("配置已重新加载:%s", strings.Join(files, ","))
+	for _, v := range listeners {
+		before, _ := lookupSetting(old, v.key)
+		after, _ := lookupSetting(current, v.key)
+		if !reflect.DeepEqual(before, after) {
+			v.fn(before, after)
+		}
+	}
+	return nil
+}
+
+// validate 用Bind注册的校验函数校验新配置，调用时需持有锁
+func (c *config) validate(settings map[string]any) error {
+	snapshot := viper.New()
+	if err := snapshot.MergeConfigMap(settings); err != nil {
+		return err
+	}
+	value := &config{Viper: snapshot, validators: make(map[string]func(Config) error), overrides: make(map[string]bool)}
+	var errs []error
+	for _, fn := range c.validators {
+		if err := fn(value); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,103 @@
+package hopter
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// writeConfig 写入配置文件
+func writeConfig(t *testing.T, file, content string) {
+	t.Helper()
+	if err := os.WriteFile(file, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReloadKeepsOldOnInvalid(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfig(t, file, "server:\n  port: 8080\nlog:\n  level: info\n")
+	conf := NewConfig(file, "")
+	conf.ReadInConfig()
+	if _, err := Bind[ginConfig](conf, "server"); err != nil {
+		t.Fatalf("Bind: %v", err)
+	}
+	var called atomic.Int32
+	conf.OnChange("server.port", func(_, _ any) {
+		called.Add(1)
+	})
+	writeConfig(t, file, "server:\n  port: abc\nlog:\n  level: debug\n")
+	if err := conf.reload(); err == nil {
+		t.Fatal("reload: expected validation error")
+	}
+	if port := conf.GetString("server.port"); port != "8080" {
+		t.Fatalf("server.port = %s, want 8080", port)
+	}
+	if level := conf.GetString("log.level"); level != "info" {
+		t.Fatalf("log.level = %s, want info", level)
+	}
+	if called.Load() != 0 {
+		t.Fatal("OnChange called for rejected reload")
+	}
+}
+
+func TestOnChangeOnlyOnChange(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfig(t, file, "server:\n  port: 8080\nlog:\n  level: info\n")
+	conf := NewConfig(file, "")
+	conf.ReadInConfig()
+	var port, level atomic.Int32
+	var got atomic.Value
+	conf.OnChange("server.port", func(old, new any) {
+		port.Add(1)
+		got.Store([2]any{old, new})
+	})
+	conf.OnChange("log.level", func(_, _ any) {
+		level.Add(1)
+	})
+	writeConfig(t, file, "server:\n  port: 9090\nlog:\n  level: info\n")
+	if err := conf.reload(); err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if port.Load() != 1 || level.Load() != 0 {
+		t.Fatalf("OnChange calls: port=%d level=%d, want 1 and 0", port.Load(), level.Load())
+	}
+	if v := got.Load().([2]any); v[0] != 8080 || v[1] != 9090 {
+		t.Fatalf("OnChange(old, new) = %v", v)
+	}
+	// 内容不变时不通知
+	if err := conf.reload(); err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if port.Load() != 1 {
+		t.Fatalf("OnChange called without change: %d", port.Load())
+	}
+}
+
+func TestWatchReloads(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfig(t, file, "server:\n  port: 8080\n")
+	conf := NewConfig(file, "")
+	conf.ReadInConfig()
+	changed := make(chan any, 1)
+	conf.OnChange("server.port", func(_, new any) {
+		changed <- new
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := conf.Watch(ctx); err != nil {
+		t.Fatalf("Watch: %v", err)
+	}
+	writeConfig(t, file, "server:\n  port: 9090\n")
+	select {
+	case v := <-changed:
+		if v != 9090 {
+			t.Fatalf("server.port = %v, want 9090", v)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("config file change was not reloaded")
+	}
+}
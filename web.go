@@ -2,9 +2,15 @@ package hopter
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
-	"strings"
+	"os/signal"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -17,8 +23,40 @@ type Engine struct {
 	beanFactory *BeanFactory
 	server      *http.Server
 	Endpoint    *Endpoint
+	// admin 管理端口，未配置时为nil
+	admin       *gin.Engine
+	adminServer *http.Server
+	// shutdownTimeout 优雅关闭时等待请求处理完成的最长时间
+	shutdownTimeout time.Duration
+	// beanTimeout 单个bean初始化和关闭的最长时间
+	beanTimeout time.Duration
+	// routes 通过Handle注册的路由，用于生成OpenAPI文档
+	routes []route
+	// securitySchemes OpenAPI认证方式
+	securitySchemes map[string]*SecurityScheme
+	// scopes 请求作用域的依赖
+	scopes map[reflect.Type]scopeProvider
+	// injects 服务和中间件中需要在启动前注入的对象
+	injects []any
+	// services 已挂载的服务
+	services []Service
+	// sessions 是否使用了session，启动时检查session密钥
+	sessions bool
+	// ready 服务是否就绪
+	ready atomic.Bool
+	// hooks 关闭时执行的钩子
+	hooks        []ShutdownHook
+	hooksMu      sync.Mutex
+	shutdownOnce sync.Once
+	shutdownDone chan struct{}
+	shutdownErr  error
+	// err 创建时读取配置的错误，由Run返回
+	err error
 }
 
+// ShutdownHook 服务关闭钩子
+type ShutdownHook func(ctx context.Context) error
+
 func init() {
 	gin.SetMode(gin.ReleaseMode)
 }
@@ -26,14 +64,20 @@ func init() {
 // New 创建web程序
 func New(conf Config, cfg, prefix string) *Engine {
 	var this = &Engine{}
-	logger, err := initLog(conf.ReadInConfig())
+	conf.ReadInConfig()
+	if c, ok := conf.(configErrorer); ok {
+		if err := c.readErr(); err != nil {
+			this.err = fmt.Errorf("web服务启动失败:%v", err)
+		}
+	}
+	logger, err := initLog(conf)
 	if err != nil {
 		Fatal("web服务启动失败:初始化日志错误，%v", err)
 	}
-	switch strings.ToLower(Level) {
-	case "debug":
+	// server配置不合法时由Run返回错误，这里只读取debug模式
+	if server, _ := Bind[ginConfig](conf, "server"); server.Debug {
 		gin.SetMode(gin.DebugMode)
-	default:
+	} else {
 		gin.SetMode(gin.ReleaseMode)
 	}
 	this.server = &http.Server{
@@ -44,13 +88,48 @@ func New(conf Config, cfg, prefix string) *Engine {
 		IdleTimeout:    30 * time.Second,
 		MaxHeaderBytes: 16384,
 	}
-	this.engine = gin.Default()
+	// 访问日志和panic恢复由accessLog和recovered提供，不使用gin.Default的Logger和Recovery
+	this.engine = gin.New()
+	this.group = &this.engine.RouterGroup
+	this.shutdownDone = make(chan struct{})
 	this.beanFactory = NewBeanFactory()
 	this.Endpoint = &Endpoint{conf, logger}
-	this.beanFactory.set(this, this.Endpoint)
+	this.beanFactory.set(this, this.Endpoint, conf, logger)
+	this.scopes = make(map[reflect.Type]scopeProvider)
+	// 访问日志在最外层，panic恢复后的响应同样记录
+	accessLog, err := accessLogMiddleware(conf)
+	if err != nil {
+		Fatal("web服务启动失败:初始化访问日志错误，%v", err)
+	}
+	this.engine.Use(requestIDMiddleware())
+	tracing, err := this.initTrace(conf)
+	if err != nil {
+		Fatal("web服务启动失败:初始化链路追踪错误，%v", err)
+	}
+	if tracing != nil {
+		this.engine.Use(tracing)
+	}
+	this.engine.Use(accessLog)
 	this.engine.Use(recovered())
-	this.engine.Use(LogMiddleware())
-	this.metric()
+	this.engine.Use(this.scopeMiddleware())
+	if err := this.initAdmin(conf); err != nil {
+		Fatal("web服务启动失败:%v", err)
+	}
+	if err := this.metric(); err != nil {
+		Fatal("web服务启动失败:%v", err)
+	}
+	logger.watchLevel(conf)
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := conf.Watch(ctx); err != nil {
+		Warn("配置热加载未开启:%v", err)
+	}
+	this.OnShutdown(func(context.Context) error {
+		cancel()
+		return nil
+	})
+	if this.admin == nil {
+		this.engine.GET(healthPath, this.health)
+	}
 	return this
 }
 
@@ -64,43 +143,137 @@ type Message interface {
 	Send(ctx Context) any
 }
 
+// StatusMessage 指定HTTP状态码的消息体，未实现时返回200
+type StatusMessage interface {
+	Message
+	StatusCode() int
+}
+
 // HandlerFunc  处理函数
 type HandlerFunc func(ctx *Context) Message
 
-// Func 消息返回处理
+// Func 消息返回处理，返回的Message自动写入响应
 func (h HandlerFunc) Func() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
-		h(&Context{ctx})
+		c := &Context{ctx}
+		if msg := h(c); msg != nil {
+			render(c, msg)
+		}
 	}
 }
 
-// Handle  重载gin的handle方法
-func (e *Engine) Handle(httpMethod, relativePath string, handler HandlerFunc) *Engine {
-	e.group.Handle(httpMethod, relativePath, handler.Func())
+// Handle  重载gin的handle方法，在Mount的Handles中调用时注册到挂载的路由组，否则注册到根路由
+func (e *Engine) Handle(httpMethod, relativePath string, handler HandlerFunc, opts ...RouteOption) *Engine {
+	e.handle(e.group, httpMethod, relativePath, handler, nil, opts...)
 	return e
 }
 
-// Run 运行Web程序
-func (e *Engine) Run() {
-	if bean := e.beanFactory.get(new(Endpoint)); bean != nil {
-		if conf, ok := bean.(*Endpoint); ok {
-			value := defaultGinConfig()
-			if v := conf.Config().Get("server"); v != nil {
-				if err := conf.Config().UnmarshalKey("server", value); err != nil {
-					Fatal("web服务启动失败:获取启动参数异常,%v", err)
-				}
-			}
-			e.server.Addr = fmt.Sprintf("%s:%s", value.IP, value.Port)
-			e.server.ReadTimeout = time.Duration(value.ReadTimeout) * time.Second
-			e.server.WriteTimeout = time.Duration(value.WriteTimeout) * time.Second
-			e.server.IdleTimeout = time.Duration(value.IdleTimeout) * time.Second
-			e.server.MaxHeaderBytes = value.MaxHeaderBytes
-		}
+// HandleTyped 注册Typed创建的处理函数，按处理函数的请求和响应类型生成OpenAPI文档
+func (e *Engine) HandleTyped(httpMethod, relativePath string, handler *TypedHandler, opts ...RouteOption) *Engine {
+	e.handle(e.group, httpMethod, relativePath, handler.handler, handler.typed, opts...)
+	return e
+}
+
+// loadServerConfig 读取服务器配置并应用到http.Server
+func (e *Engine) loadServerConfig() error {
+	value, err := Bind[ginConfig](e.Endpoint.Config(), "server")
+	if err != nil {
+		return fmt.Errorf("web服务启动失败:%v", err)
 	}
+	e.server.Addr = fmt.Sprintf("%s:%s", value.IP, value.Port)
+	e.server.ReadTimeout = time.Duration(value.ReadTimeout) * time.Second
+	e.server.WriteTimeout = time.Duration(value.WriteTimeout) * time.Second
+	e.server.IdleTimeout = time.Duration(value.IdleTimeout) * time.Second
+	e.server.MaxHeaderBytes = value.MaxHeaderBytes
+	e.shutdownTimeout = value.ShutdownTimeout
+	e.beanTimeout = value.BeanTimeout
 	e.server.Handler = e.engine
-	if err := e.server.ListenAndServe(); err != nil {
-		Fatal("web服务启动失败:服务器监听端口异常，%v", err)
+	if e.sessions {
+		if _, err := SessionKeyPairs(e.Endpoint.Config()); err != nil {
+			return err
+		}
 	}
+	return applyTLS(e.server, &value.TLS)
+}
+
+// Run 运行Web程序，收到SIGINT/SIGTERM后优雅关闭
+func (e *Engine) Run() error {
+	return e.RunContext(context.Background())
+}
+
+// RunContext 运行Web程序，ctx取消或收到SIGINT/SIGTERM后优雅关闭
+func (e *Engine) RunContext(ctx context.Context) error {
+	// 启动失败时同样关闭New中打开的配置监听、链路追踪和日志文件
+	if e.err != nil {
+		return e.abort(e.err)
+	}
+	if err := e.loadServerConfig(); err != nil {
+		return e.abort(err)
+	}
+	// 补充注入服务和中间件注册之后才注册的bean
+	if err := e.inject(); err != nil {
+		return e.abort(err)
+	}
+	ctx, stop := signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	// 初始化失败时关闭已初始化的bean
+	if err := e.initBeans(ctx); err != nil {
+		return e.abort(err)
+	}
+	listener, err := net.Listen("tcp", e.server.Addr)
+	if err != nil {
+		return e.abort(fmt.Errorf("web服务启动失败:服务器监听端口异常，%v", err))
+	}
+	errCh := make(chan error, 2)
+	go func() {
+		if e.server.TLSConfig != nil {
+			// 证书由TLSConfig.GetCertificate提供
+			errCh <- e.server.ServeTLS(listener, "", "")
+			return
+		}
+		errCh <- e.server.Serve(listener)
+	}()
+	if e.adminServer != nil {
+		adminListener, err := net.Listen("tcp", e.adminServer.Addr)
+		if err != nil {
+			return e.abort(fmt.Errorf("web服务启动失败:管理端口监听异常，%v", err))
+		}
+		go func() {
+			errCh <- e.adminServer.Serve(adminListener)
+		}()
+	}
+	if err := e.start(ctx); err != nil {
+		return e.abort(err)
+	}
+	select {
+	case err := <-errCh:
+		// 外部调用Shutdown时返回ErrServerClosed，属于正常关闭
+		if errors.Is(err, http.ErrServerClosed) {
+			<-e.shutdownDone
+			return e.shutdownErr
+		}
+		return e.abort(fmt.Errorf("web服务异常退出:%v", err))
+	case <-ctx.Done():
+	}
+	Info("web服务正在关闭，等待处理中的请求完成...")
+	shutdownCtx, cancel := e.shutdownContext()
+	defer cancel()
+	return e.Shutdown(shutdownCtx)
+}
+
+// abort 启动失败或异常退出时关闭服务，返回err和关闭时的错误
+func (e *Engine) abort(err error) error {
+	shutdownCtx, cancel := e.shutdownContext()
+	defer cancel()
+	return errors.Join(err, e.Shutdown(shutdownCtx))
+}
+
+// shutdownContext 优雅关闭的context，shutdownTimeout为0时不限制等待时间
+func (e *Engine) shutdownContext() (context.Context, context.CancelFunc) {
+	if e.shutdownTimeout <= 0 {
+		return context.WithCancel(context.Background())
+	}
+	return context.WithTimeout(context.Background(), e.shutdownTimeout)
 }
 
 // Routes 返回路由列表
@@ -108,7 +281,44 @@ func (e *Engine) Routes() gin.RoutesInfo {
 	return e.engine.Routes()
 }
 
-// Shutdown 关闭服务
+// OnShutdown 注册关闭钩子，关闭时按注册的相反顺序执行
+func (e *Engine) OnShutdown(hooks ...ShutdownHook) *Engine {
+	e.hooksMu.Lock()
+	defer e.hooksMu.Unlock()
+	e.hooks = append(e.hooks, hooks...)
+	return e
+}
+
+// Shutdown 关闭服务，依次停止接收请求、执行关闭钩子、刷新日志文件
+// 多次调用只会执行一次
 func (e *Engine) Shutdown(ctx context.Context) error {
-	return e.server.Shutdown(ctx)
+	e.shutdownOnce.Do(func() {
+		e.ready.Store(false)
+		var errs []error
+		if err := e.server.Shutdown(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("web服务关闭异常:%v", err))
+		}
+		if e.adminServer != nil {
+			if err := e.adminServer.Shutdown(ctx); err != nil {
+				errs = append(errs, fmt.Errorf("管理端口关闭异常:%v", err))
+			}
+		}
+		// 服务启动时会注册Stopper钩子，可能与外部调用的Shutdown并发
+		e.hooksMu.Lock()
+		hooks := e.hooks
+		e.hooksMu.Unlock()
+		for i := len(hooks) - 1; i >= 0; i-- {
+			if err := hooks[i](ctx); err != nil {
+				errs = append(errs, fmt.Errorf("web服务关闭钩子执行异常:%v", err))
+			}
+		}
+		if e.Endpoint != nil && e.Endpoint.Logs() != nil {
+			if err := e.Endpoint.Logs().Close(); err != nil {
+				errs = append(errs, fmt.Errorf("日志关闭异常:%v", err))
+			}
+		}
+		e.shutdownErr = errors.Join(errs...)
+		close(e.shutdownDone)
+	})
+	return e.shutdownErr
 }
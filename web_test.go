@@ -0,0 +1,175 @@
+package hopter
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// freePort 得到一个空闲端口
+func freePort(t *testing.T) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	_, port, _ := net.SplitHostPort(l.Addr().String())
+	return port
+}
+
+// newTestServer 日志写入临时目录、监听本机空闲端口的Engine，返回服务地址
+func newTestServer(t *testing.T, settings map[string]any) (*Engine, string) {
+	t.Helper()
+	conf := NewConfig("", "")
+	conf.Set("log.path", filepath.Join(t.TempDir(), "server.log"))
+	conf.Set("server.ip", "127.0.0.1")
+	port := freePort(t)
+	conf.Set("server.port", port)
+	for k, v := range settings {
+		conf.Set(k, v)
+	}
+	return New(conf, "", ""), "http://127.0.0.1:" + port
+}
+
+// runTestServer 在后台运行服务，等待端口可以连接后返回RunContext的结果通道
+func runTestServer(t *testing.T, ctx context.Context, e *Engine, addr string) <-chan error {
+	t.Helper()
+	done := make(chan error, 1)
+	go func() {
+		done <- e.RunContext(ctx)
+	}()
+	host := addr[len("http://"):]
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		conn, err := net.Dial("tcp", host)
+		if err == nil {
+			conn.Close()
+			return done
+		}
+		select {
+		case err := <-done:
+			t.Fatalf("RunContext exited early: %v", err)
+		default:
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("server did not start: %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// waitRun 等待RunContext返回
+func waitRun(t *testing.T, done <-chan error) error {
+	t.Helper()
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(5 * time.Second):
+		t.Fatal("RunContext did not return")
+		return nil
+	}
+}
+
+func TestRunContextDrainsInFlight(t *testing.T) {
+	e, addr := newTestServer(t, nil)
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	e.Handle("GET", "/slow", func(ctx *Context) Message {
+		close(entered)
+		<-release
+		ctx.String(http.StatusOK, "done")
+		return nil
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := runTestServer(t, ctx, e, addr)
+	type result struct {
+		body string
+		err  error
+	}
+	resCh := make(chan result, 1)
+	go func() {
+		resp, err := http.Get(addr + "/slow")
+		if err != nil {
+			resCh <- result{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		b, err := io.ReadAll(resp.Body)
+		resCh <- result{string(b), err}
+	}()
+	<-entered
+	cancel()
+	// 关闭开始后处理中的请求仍能完成
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+	res := <-resCh
+	if res.err != nil || res.body != "done" {
+		t.Fatalf("in-flight request = %q, %v", res.body, res.err)
+	}
+	if err := waitRun(t, done); err != nil {
+		t.Fatalf("RunContext = %v, want nil", err)
+	}
+}
+
+func TestShutdownStopsRun(t *testing.T) {
+	e, addr := newTestServer(t, nil)
+	done := runTestServer(t, context.Background(), e, addr)
+	if err := e.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	if err := waitRun(t, done); err != nil {
+		t.Fatalf("Run = %v, want nil", err)
+	}
+	if _, err := http.Get(addr + "/health"); err == nil {
+		t.Fatal("server still accepts requests after Shutdown")
+	}
+}
+
+func TestRunFailureShutsDown(t *testing.T) {
+	e, _ := newTestServer(t, map[string]any{"server.port": "not-a-port"})
+	closed := false
+	e.OnShutdown(func(context.Context) error {
+		closed = true
+		return nil
+	})
+	err := e.RunContext(context.Background())
+	if err == nil {
+		t.Fatal("RunContext: expected config error")
+	}
+	if !closed {
+		t.Fatal("shutdown hooks did not run after startup failure")
+	}
+	select {
+	case <-e.shutdownDone:
+	default:
+		t.Fatal("Shutdown was not called")
+	}
+}
+
+func TestShutdownTimeoutDuration(t *testing.T) {
+	conf := NewConfig("", "")
+	conf.Set("server.shutdownTimeout", 0)
+	conf.Set("server.beanTimeout", "2m")
+	value, err := Bind[ginConfig](conf, "server")
+	if err != nil {
+		t.Fatalf("Bind: %v", err)
+	}
+	if value.ShutdownTimeout != 0 || value.BeanTimeout != 2*time.Minute {
+		t.Fatalf("timeouts = %v, %v", value.ShutdownTimeout, value.BeanTimeout)
+	}
+	conf.Set("server.shutdownTimeout", 30)
+	if value, _ = Bind[ginConfig](conf, "server"); value.ShutdownTimeout != 30*time.Second {
+		t.Fatalf("shutdownTimeout = %v, want 30s", value.ShutdownTimeout)
+	}
+	e := &Engine{}
+	ctx, cancel := e.shutdownContext()
+	defer cancel()
+	if _, ok := ctx.Deadline(); ok {
+		t.Fatal("zero shutdownTimeout should not set a deadline")
+	}
+}